@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// Bind decodes the request into the value pointed to by v, choosing a decoder
+// from the request Content-Type: JSON and XML bodies are decoded with
+// encoding/json and encoding/xml, while urlencoded and multipart forms fill
+// struct fields by their `form` tag. Any decode errors are attached to the Ctx
+// as ErrorTypeExternal. A decoded struct is then checked with Validate, and
+// any failure is attached to the Ctx as ErrorTypeValidation. On any error,
+// the group's 400 HttpStatus is called and the first error is returned, except
+// for a malformed validate tag, attached as ErrorTypeInternal with the 500
+// HttpStatus called.
+func (c *Ctx) Bind(v interface{}) error {
+	ct, _, _ := mime.ParseMediaType(c.request.Header.Get("Content-Type"))
+	var errs []error
+	switch {
+	case ct == "application/json" || strings.HasSuffix(ct, "+json"):
+		errs = decodeBody(json.NewDecoder(bodyOf(c.request)), v)
+	case ct == "application/xml" || ct == "text/xml" || strings.HasSuffix(ct, "+xml"):
+		errs = decodeBody(xml.NewDecoder(bodyOf(c.request)), v)
+	case ct == "application/x-www-form-urlencoded" || ct == "multipart/form-data":
+		errs = bindForm(v, c.form, c.files)
+	default:
+		errs = []error{newError("cannot bind a request with Content-Type '%s'", ct)}
+	}
+	for _, err := range errs {
+		c.Error(err, ct)
+	}
+	if len(errs) > 0 {
+		c.Status(400)
+		return errs[0]
+	}
+	if reflect.Indirect(reflect.ValueOf(v)).Kind() == reflect.Struct {
+		if err := Validate(v); err != nil {
+			ve, ok := err.(ValidationErrors)
+			if !ok {
+				// a malformed validate tag
+				c.errorTyped(err, ErrorTypeInternal, nil)
+				c.Status(500)
+				return err
+			}
+			for _, fe := range ve {
+				c.errorTyped(fe, ErrorTypeValidation, fe)
+			}
+			c.Status(400)
+			return err
+		}
+	}
+	return nil
+}
+
+type decoder interface {
+	Decode(v interface{}) error
+}
+
+func bodyOf(req *http.Request) io.Reader {
+	if req.Body == nil {
+		return strings.NewReader("")
+	}
+	return req.Body
+}
+
+func decodeBody(d decoder, v interface{}) []error {
+	if err := d.Decode(v); err != nil {
+		return []error{err}
+	}
+	return nil
+}
+
+// bindForm fills the fields of the struct pointed to by v from form values
+// and files, keyed by the `form` tag of each field, e.g. `form:"name"`. Slice
+// fields take every value for the key, *multipart.FileHeader fields take the
+// first file and []*multipart.FileHeader fields take every file.
+func bindForm(v interface{}, form url.Values, files map[string][]*multipart.FileHeader) []error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return []error{newError("a form can only bind to a struct pointer, not %T", v)}
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	var errs []error
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		name := f.Tag.Get("form")
+		if name == "" || name == "-" || f.PkgPath != "" {
+			continue
+		}
+		field := rv.Field(i)
+		switch {
+		case field.Type() == fileHeaderType:
+			if fhs := files[name]; len(fhs) > 0 {
+				field.Set(reflect.ValueOf(fhs[0]))
+			}
+		case field.Type() == fileHeadersType:
+			if fhs := files[name]; len(fhs) > 0 {
+				field.Set(reflect.ValueOf(fhs))
+			}
+		case field.Kind() == reflect.Slice:
+			values := form[name]
+			if len(values) == 0 {
+				continue
+			}
+			slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+			for j, value := range values {
+				if err := setField(slice.Index(j), value); err != nil {
+					errs = append(errs, formError(name, value, slice.Index(j)))
+				}
+			}
+			field.Set(slice)
+		default:
+			if values := form[name]; len(values) > 0 {
+				if err := setField(field, values[0]); err != nil {
+					errs = append(errs, formError(name, values[0], field))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func formError(name string, value string, field reflect.Value) error {
+	return newError("form field %s value '%s' is not a valid %s", name, value, field.Type())
+}
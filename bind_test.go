@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+type boundBody struct {
+	Name  string                `json:"name" xml:"name" form:"name"`
+	Age   int                   `json:"age" xml:"age" form:"age"`
+	Tags  []string              `json:"tags" xml:"tag" form:"tag"`
+	File  *multipart.FileHeader `json:"-" xml:"-" form:"file"`
+	Other string                `json:"-" xml:"-"`
+}
+
+func performBind(contentType string, body string) (*httptest.ResponseRecorder, boundBody, errorMsgs) {
+	var bound boundBody
+	var errs errorMsgs
+
+	e, _ := New()
+	e.Take("/bind", "POST", func(c context.Context) {
+		curr := currentCtx(c)
+		if err := curr.Bind(&bound); err != nil {
+			errs = curr.Errors.ByType(ErrorTypeExternal)
+		}
+	})
+
+	req, _ := http.NewRequest("POST", "/bind", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w, bound, errs
+}
+
+func testBind(contentType string, body string, t *testing.T) {
+	w, bound, errs := performBind(contentType, body)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("%s status code should be %v, was %d: %s", contentType, http.StatusOK, w.Code, errs)
+	}
+	expected := boundBody{Name: "gopher", Age: 5, Tags: []string{"a", "b"}}
+	if !reflect.DeepEqual(bound, expected) {
+		t.Errorf("%s bound %+v, should be %+v", contentType, bound, expected)
+	}
+}
+
+func TestBind(t *testing.T) {
+	testBind("application/json; charset=utf-8", `{"name":"gopher","age":5,"tags":["a","b"]}`, t)
+	testBind("application/xml", `<boundBody><name>gopher</name><age>5</age><tag>a</tag><tag>b</tag></boundBody>`, t)
+	testBind("application/x-www-form-urlencoded", `name=gopher&age=5&tag=a&tag=b`, t)
+}
+
+func TestBindMultipart(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("name", "gopher")
+	fw, _ := mw.CreateFormFile("file", "gopher.txt")
+	fw.Write([]byte("GOPHER"))
+	mw.Close()
+
+	w, bound, errs := performBind(mw.FormDataContentType(), body.String())
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Status code should be %v, was %d: %s", http.StatusOK, w.Code, errs)
+	}
+	if bound.Name != "gopher" {
+		t.Errorf("Name should be gopher, was %s", bound.Name)
+	}
+	if bound.File == nil || bound.File.Filename != "gopher.txt" {
+		t.Errorf("File should be gopher.txt, was %+v", bound.File)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	errored := []struct {
+		contentType string
+		body        string
+		errors      int
+	}{
+		{"application/json", `{"name":`, 1},
+		{"application/xml", `<boundBody><age>five</age></boundBody>`, 1},
+		{"application/x-www-form-urlencoded", `age=five&tag=a`, 1},
+		{"text/plain", `gopher`, 1},
+	}
+	for _, e := range errored {
+		w, _, errs := performBind(e.contentType, e.body)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s status code should be %v, was %d", e.contentType, http.StatusBadRequest, w.Code)
+		}
+		if len(errs) != e.errors {
+			t.Errorf("%s should have %d external errors, had %d", e.contentType, e.errors, len(errs))
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package engine
 
 import (
+	"crypto/tls"
 	"log"
 	"os"
 	"reflect"
+	"time"
 )
 
 type (
@@ -15,9 +17,21 @@ type (
 		ServePanic            bool
 		RedirectTrailingSlash bool
 		RedirectFixedPath     bool
+		MethodNotAllowed      bool
+		AutoOptions           bool
+		ImplicitHead          bool
 		HTMLStatus            bool
+		JSONStatus            bool
 		LoggingOn             bool
 		MaxFormMemory         int64
+		ReloadTemplates       bool
+		Timeout               time.Duration
+		ReadTimeout           time.Duration
+		WriteTimeout          time.Duration
+		IdleTimeout           time.Duration
+		GracePeriod           time.Duration
+		CertReload            time.Duration
+		HTTPRedirect          string
 	}
 )
 
@@ -26,9 +40,21 @@ func defaultconf() *conf {
 		ServePanic:            true,
 		RedirectTrailingSlash: true,
 		RedirectFixedPath:     true,
+		MethodNotAllowed:      true,
+		AutoOptions:           false,
+		ImplicitHead:          false,
 		HTMLStatus:            false,
+		JSONStatus:            false,
 		LoggingOn:             false,
 		MaxFormMemory:         1000000,
+		ReloadTemplates:       false,
+		Timeout:               0,
+		ReadTimeout:           0,
+		WriteTimeout:          0,
+		IdleTimeout:           0,
+		GracePeriod:           10 * time.Second,
+		CertReload:            10 * time.Second,
+		HTTPRedirect:          "",
 	}
 }
 
@@ -63,12 +89,46 @@ func RedirectFixedPath(b bool) Conf {
 	}
 }
 
+// MethodNotAllowed sets whether a request for a path registered only under
+// other methods is answered with a 405 status and an Allow header, rather than
+// a 404 status.
+func MethodNotAllowed(b bool) Conf {
+	return func(e *Engine) error {
+		return e.SetConfBool("MethodNotAllowed", b)
+	}
+}
+
+// AutoOptions sets whether an OPTIONS request for a path without a registered
+// OPTIONS Manage is answered with a 204 status and an Allow header listing the
+// methods registered for the path.
+func AutoOptions(b bool) Conf {
+	return func(e *Engine) error {
+		return e.SetConfBool("AutoOptions", b)
+	}
+}
+
+// ImplicitHead sets whether a HEAD request for a path without a registered
+// HEAD Manage runs the GET Manage for the path, discarding the response body.
+func ImplicitHead(b bool) Conf {
+	return func(e *Engine) error {
+		return e.SetConfBool("ImplicitHead", b)
+	}
+}
+
 func HTMLStatus(b bool) Conf {
 	return func(e *Engine) error {
 		return e.SetConfBool("HTMLStatus", b)
 	}
 }
 
+// JSONStatus sets whether a HttpStatus is served as a json Problem when the
+// request Accept header does not prefer either of json or html.
+func JSONStatus(b bool) Conf {
+	return func(e *Engine) error {
+		return e.SetConfBool("JSONStatus", b)
+	}
+}
+
 // Logger specifies a log.Logger, and sets LoggingOn to true, and capturing
 // signals with Head labeled "do-log"
 func Logger(l *log.Logger) Conf {
@@ -79,6 +139,24 @@ func Logger(l *log.Logger) Conf {
 	}
 }
 
+// RequestLog specifies a RequestLogger for structured request logging, e.g.
+// NewRequestLogger(os.Stdout, JSONFormatter{}), and sets LoggingOn to true.
+func RequestLog(l RequestLogger) Conf {
+	return func(e *Engine) error {
+		e.RequestLog = l
+		return e.SetConfBool("LoggingOn", true)
+	}
+}
+
+// CollectMetrics specifies Metrics collecting every request served, to be
+// mounted on a route with Metrics.Manage.
+func CollectMetrics(m *Metrics) Conf {
+	return func(e *Engine) error {
+		e.Metrics = m
+		return nil
+	}
+}
+
 // LogginOn sets Logger to a default log.Logger and sets LoggingOn to true.
 func LoggingOn(b bool) Conf {
 	return func(e *Engine) error {
@@ -93,6 +171,81 @@ func MaxFormMemory(byts int64) Conf {
 	}
 }
 
+// ReloadTemplates sets whether Ctx.Render reloads Templates when their files
+// change, for development.
+func ReloadTemplates(b bool) Conf {
+	return func(e *Engine) error {
+		return e.SetConfBool("ReloadTemplates", b)
+	}
+}
+
+// Timeout sets a timeout for requests to every group without its own timeout,
+// see Group.SetTimeout, and to routes registered directly on the engine. A
+// zero duration sets no timeout.
+func Timeout(d time.Duration) Conf {
+	return func(e *Engine) error {
+		return e.SetConfInt64("Timeout", int64(d))
+	}
+}
+
+// ReadTimeout sets the maximum duration of a Server reading a request,
+// including the body. A zero duration sets no timeout.
+func ReadTimeout(d time.Duration) Conf {
+	return func(e *Engine) error {
+		return e.SetConfInt64("ReadTimeout", int64(d))
+	}
+}
+
+// WriteTimeout sets the maximum duration of a Server writing a response. A
+// zero duration sets no timeout.
+func WriteTimeout(d time.Duration) Conf {
+	return func(e *Engine) error {
+		return e.SetConfInt64("WriteTimeout", int64(d))
+	}
+}
+
+// IdleTimeout sets the maximum duration a Server keeps an idle keep-alive
+// connection open. A zero duration uses the ReadTimeout.
+func IdleTimeout(d time.Duration) Conf {
+	return func(e *Engine) error {
+		return e.SetConfInt64("IdleTimeout", int64(d))
+	}
+}
+
+// GracePeriod sets how long a Server shutting down waits for in-flight
+// requests before closing their connections.
+func GracePeriod(d time.Duration) Conf {
+	return func(e *Engine) error {
+		return e.SetConfInt64("GracePeriod", int64(d))
+	}
+}
+
+// TLSConfig sets the tls.Config used to serve TLS, with certificates from the
+// files given to RunTLS.
+func TLSConfig(c *tls.Config) Conf {
+	return func(e *Engine) error {
+		e.TLSConfig = c
+		return nil
+	}
+}
+
+// CertReload sets how often a Server serving TLS checks the certificate and key
+// files for changes, reloading them without a restart. A zero duration checks
+// on every handshake.
+func CertReload(d time.Duration) Conf {
+	return func(e *Engine) error {
+		return e.SetConfInt64("CertReload", int64(d))
+	}
+}
+
+// HTTPRedirect sets an address for a Server serving TLS to also listen on,
+// redirecting plain HTTP requests to HTTPS. An empty address does not listen.
+func HTTPRedirect(addr string) Conf {
+	return func(e *Engine) error {
+		return e.SetConfString("HTTPRedirect", addr)
+	}
+}
+
 func (e *Engine) elem() reflect.Value {
 	v := reflect.ValueOf(e)
 	return v.Elem()
@@ -111,6 +264,15 @@ func (e *Engine) SetConfInt64(fieldname string, as int64) error {
 	return newError("Engine could not set field %s as %d", fieldname, as)
 }
 
+func (e *Engine) SetConfString(fieldname string, as string) error {
+	f := e.getfield(fieldname)
+	if f.CanSet() {
+		f.SetString(as)
+		return nil
+	}
+	return newError("Engine could not set field %s as %s", fieldname, as)
+}
+
 func (e *Engine) SetConfBool(fieldname string, as bool) error {
 	f := e.getfield(fieldname)
 	if f.CanSet() {
@@ -1,10 +1,12 @@
 package engine
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
 	"os"
 	"reflect"
+	"time"
 
 	"testing"
 )
@@ -36,14 +38,32 @@ func testConf(testitems []*testitem, t *testing.T) {
 
 func TestConf(t *testing.T) {
 	l := log.New(os.Stdout, "[TEST]", 0)
+	tlsc := &tls.Config{}
+	rl := NewRequestLogger(os.Stdout, JSONFormatter{})
+	m := NewMetrics()
 	tc := []*testitem{
 		&testitem{ServePanic(false), "ServePanic", false},
 		&testitem{RedirectTrailingSlash(false), "RedirectTrailingSlash", false},
 		&testitem{RedirectFixedPath(false), "RedirectFixedPath", false},
+		&testitem{MethodNotAllowed(false), "MethodNotAllowed", false},
+		&testitem{AutoOptions(true), "AutoOptions", true},
+		&testitem{ImplicitHead(true), "ImplicitHead", true},
 		&testitem{HTMLStatus(true), "HTMLStatus", true},
+		&testitem{JSONStatus(true), "JSONStatus", true},
 		&testitem{LoggingOn(true), "LoggingOn", true},
 		&testitem{Logger(l), "Logger", l},
 		&testitem{MaxFormMemory(500), "MaxFormMemory", int64(500)},
+		&testitem{ReloadTemplates(true), "ReloadTemplates", true},
+		&testitem{Timeout(time.Second), "Timeout", time.Second},
+		&testitem{ReadTimeout(time.Second), "ReadTimeout", time.Second},
+		&testitem{WriteTimeout(time.Second), "WriteTimeout", time.Second},
+		&testitem{IdleTimeout(time.Second), "IdleTimeout", time.Second},
+		&testitem{GracePeriod(time.Second), "GracePeriod", time.Second},
+		&testitem{CertReload(time.Second), "CertReload", time.Second},
+		&testitem{HTTPRedirect(":8080"), "HTTPRedirect", ":8080"},
+		&testitem{TLSConfig(tlsc), "TLSConfig", tlsc},
+		&testitem{RequestLog(rl), "RequestLog", rl},
+		&testitem{CollectMetrics(m), "Metrics", m},
 	}
 	testConf(tc, t)
 }
@@ -3,9 +3,11 @@ package engine
 import (
 	"errors"
 	"fmt"
+	"math"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
 	"golang.org/x/net/context"
@@ -14,7 +16,14 @@ import (
 type (
 	// Ctx is the core request-response context passed between any Manage
 	// handlers, useful for storing & persisting data within a request & response.
+	// Ctx is a context.Context, carrying the deadline, cancellation and values of
+	// the request context. A Ctx is reused once its request is served, and must
+	// not be kept past it, as a context.Context or otherwise. Goroutines
+	// outliving the request should use the context.Context passed to the
+	// Manage, or one derived from it, which stays valid but no longer carries
+	// the Ctx once the request is served.
 	Ctx struct {
+		ctx     *requestCtx
 		engine  *Engine
 		group   *Group
 		rwmem   responseWriter
@@ -25,6 +34,24 @@ type (
 		files   map[string][]*multipart.FileHeader
 		Errors  errorMsgs
 		*recorder
+		current  context.Context
+		handlers []Manage
+		index    int
+		expired  *Ctx
+		keys     map[string]interface{}
+		route    string
+	}
+
+	// ctxKey is the type of context.Context keys defined by the engine.
+	ctxKey int
+
+	// requestCtx is the context.Context passed to the Manage of a request,
+	// carrying its Ctx until the Ctx is released for reuse. Unlike the Ctx it
+	// is never reused, so a context.Context derived from it is safe to keep.
+	requestCtx struct {
+		context.Context
+		mu   sync.RWMutex
+		curr *Ctx
 	}
 
 	recorder struct {
@@ -35,20 +62,29 @@ type (
 		method    string
 		path      string
 		requester string
+		id        string
 	}
 )
 
+const abortIndex = math.MaxInt32
+
+// currentKey is the context.Context key for the Ctx of a request.
+const currentKey ctxKey = 0
+
 func (engine *Engine) newCtx() interface{} {
 	c := &Ctx{engine: engine}
 	c.RW = &c.rwmem
 	return c
 }
 
-func (engine *Engine) getCtx(w http.ResponseWriter, req *http.Request) *Ctx {
+func (engine *Engine) getCtx(w http.ResponseWriter, req *http.Request, ctx context.Context) *Ctx {
 	c := engine.cache.Get().(*Ctx)
+	c.ctx = &requestCtx{Context: ctx, curr: c}
 	c.group = engine.groups["/"]
 	c.rwmem.reset(w)
-	c.recorder = &recorder{}
+	c.RW = &c.rwmem
+	c.recorder = &recorder{id: requestID(req)}
+	c.RW.Header().Set(RequestIDHeader, c.recorder.id)
 	c.Start()
 	c.request = req
 	c.parseform()
@@ -56,20 +92,54 @@ func (engine *Engine) getCtx(w http.ResponseWriter, req *http.Request) *Ctx {
 }
 
 func (engine *Engine) putCtx(c *Ctx) {
-	c.PostProcess(c.request, c.RW)
-	if engine.LoggingOn {
-		engine.Send("message", c.LogFmt())
+	if c.expired != nil {
+		// the Manage chain of a timed out Ctx may still be running, so the
+		// Ctx is not reused
+		engine.record(c.expired)
+		return
 	}
-	engine.Send("recorder", c.Fmt())
+	engine.record(c)
+	c.ctx.release()
+	c.ctx = nil
 	c.group = nil
 	c.request = nil
 	c.Params = nil
 	c.form = nil
 	c.recorder = nil
 	c.Errors = nil
+	c.current = nil
+	c.handlers = nil
+	c.route = ""
+	for k := range c.keys {
+		delete(c.keys, k)
+	}
 	engine.cache.Put(c)
 }
 
+func (engine *Engine) record(c *Ctx) {
+	c.PostProcess(c.request, c.RW)
+	entry := c.logEntry()
+	if engine.LoggingOn {
+		engine.logRequest(entry)
+	}
+	if engine.Metrics != nil {
+		engine.Metrics.Observe(entry)
+	}
+	// the recorder line is only formatted for a "recorder" Queue or any
+	// EventRequest subscription
+	queued := engine.Queue("recorder") != nil
+	subscribed := engine.Events.subscribed(EventRequest)
+	if queued || subscribed {
+		line := c.Fmt()
+		if queued {
+			engine.Send("recorder", line)
+		}
+		if subscribed {
+			engine.publish(EventRequest, line, entry)
+		}
+	}
+}
+
 func (c *Ctx) parseform() {
 	c.request.ParseMultipartForm(c.engine.MaxFormMemory)
 	c.form = c.request.Form
@@ -78,6 +148,136 @@ func (c *Ctx) parseform() {
 	}
 }
 
+// Value returns the Ctx for the engine's own key until the Ctx is released, or
+// otherwise the value of the request context for the key.
+func (r *requestCtx) Value(key interface{}) interface{} {
+	if key == currentKey {
+		if curr := r.current(); curr != nil {
+			return curr
+		}
+		return nil
+	}
+	return r.Context.Value(key)
+}
+
+func (r *requestCtx) current() *Ctx {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.curr
+}
+
+// release detaches the Ctx, before it is reused for another request.
+func (r *requestCtx) release() {
+	r.mu.Lock()
+	r.curr = nil
+	r.mu.Unlock()
+}
+
+// Deadline returns the deadline of the request context.
+func (c *Ctx) Deadline() (time.Time, bool) {
+	return c.ctx.Deadline()
+}
+
+// Done returns a channel closed when the request context is cancelled, by the
+// client going away or the request being served.
+func (c *Ctx) Done() <-chan struct{} {
+	return c.ctx.Done()
+}
+
+// Err returns the error of the request context once Done is closed.
+func (c *Ctx) Err() error {
+	return c.ctx.Err()
+}
+
+// Value returns the Ctx for the engine's own key, or otherwise the value of the
+// request context for the key. Values set with Ctx.Set are retrieved with
+// Ctx.Get.
+func (c *Ctx) Value(key interface{}) interface{} {
+	if key == currentKey {
+		return c
+	}
+	return c.ctx.Value(key)
+}
+
+// Set stores the value with the key for the remainder of the request, e.g.
+// for an authenticated user set by middleware and read by the handler.
+func (c *Ctx) Set(key string, value interface{}) {
+	if c.keys == nil {
+		c.keys = make(map[string]interface{})
+	}
+	c.keys[key] = value
+}
+
+// Get returns the value stored with the key, and whether the key exists.
+func (c *Ctx) Get(key string) (interface{}, bool) {
+	value, exists := c.keys[key]
+	return value, exists
+}
+
+// MustGet returns the value stored with the key, panicking if it does not
+// exist.
+func (c *Ctx) MustGet(key string) interface{} {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic("no value stored with the key " + key)
+}
+
+// GetString returns the value stored with the key as a string, or "" if it
+// does not exist or is not a string.
+func (c *Ctx) GetString(key string) (s string) {
+	s, _ = c.keys[key].(string)
+	return
+}
+
+// GetBool returns the value stored with the key as a bool, or false if it does
+// not exist or is not a bool.
+func (c *Ctx) GetBool(key string) (b bool) {
+	b, _ = c.keys[key].(bool)
+	return
+}
+
+// GetInt returns the value stored with the key as an int, or 0 if it does not
+// exist or is not an int.
+func (c *Ctx) GetInt(key string) (i int) {
+	i, _ = c.keys[key].(int)
+	return
+}
+
+// GetInt64 returns the value stored with the key as an int64, or 0 if it does
+// not exist or is not an int64.
+func (c *Ctx) GetInt64(key string) (i int64) {
+	i, _ = c.keys[key].(int64)
+	return
+}
+
+// GetFloat64 returns the value stored with the key as a float64, or 0 if it
+// does not exist or is not a float64.
+func (c *Ctx) GetFloat64(key string) (f float64) {
+	f, _ = c.keys[key].(float64)
+	return
+}
+
+// GetTime returns the value stored with the key as a time.Time, or the zero
+// time if it does not exist or is not a time.Time.
+func (c *Ctx) GetTime(key string) (t time.Time) {
+	t, _ = c.keys[key].(time.Time)
+	return
+}
+
+// GetDuration returns the value stored with the key as a time.Duration, or 0
+// if it does not exist or is not a time.Duration.
+func (c *Ctx) GetDuration(key string) (d time.Duration) {
+	d, _ = c.keys[key].(time.Duration)
+	return
+}
+
+// Route returns the path pattern of the route matched for the request, e.g.
+// "/user/:name", or "" when no route matched.
+func (c *Ctx) Route() string {
+	return c.route
+}
+
 func (c *Ctx) Request() *http.Request {
 	return c.request
 }
@@ -98,15 +298,26 @@ func (c *Ctx) Files() map[string][]*multipart.FileHeader {
 	return c.files
 }
 
+// BindParams fills the tagged fields of the struct pointed to by v from the
+// Ctx Params, attaching any conversion error to the Ctx errors.
+func (c *Ctx) BindParams(v interface{}) error {
+	err := c.Params.Bind(v)
+	if err != nil {
+		c.Error(err, c.Params)
+	}
+	return err
+}
+
 func (c *Ctx) Writer() ResponseWriter {
 	return c.RW
 }
 
 func (c *Ctx) errorTyped(err error, typ uint32, meta interface{}) {
 	c.Errors = append(c.Errors, errorMsg{
-		Err:  err.Error(),
-		Type: typ,
-		Meta: meta,
+		Err:       err.Error(),
+		Type:      typ,
+		Meta:      meta,
+		RequestID: c.RequestID(),
 	})
 }
 
@@ -126,13 +337,46 @@ func (c *Ctx) LastError() error {
 	}
 }
 
-// Immediately abort the context, writing out the code to the response
+// run starts the provided chain of Manage with the context.Context.
+func (c *Ctx) run(ctx context.Context, handlers []Manage) {
+	c.current = ctx
+	c.handlers = handlers
+	c.index = -1
+	c.Next()
+}
+
+// Next runs the remaining Manage in the chain. Called from middleware, Next
+// returns after the handler has run, allowing code to run after the handler.
+func (c *Ctx) Next() {
+	c.NextWith(c.current)
+}
+
+// NextWith runs the remaining Manage in the chain with the provided
+// context.Context, which should derive from the context.Context the
+// middleware received.
+func (c *Ctx) NextWith(ctx context.Context) {
+	c.current = ctx
+	c.index++
+	for s := len(c.handlers); c.index < s; c.index++ {
+		c.handlers[c.index](c.current)
+	}
+}
+
+// Immediately abort the context, halting any remaining Manage in the chain and
+// writing out the code to the response if code is not negative.
 func (c *Ctx) Abort(code int) {
+	c.index = abortIndex
 	if code >= 0 {
 		c.RW.WriteHeader(code)
+		c.RW.WriteHeaderNow()
 	}
 }
 
+// Aborted reports whether the chain of Manage was halted with Abort.
+func (c *Ctx) Aborted() bool {
+	return c.index >= abortIndex
+}
+
 // Fail is the same as Abort plus an error message.
 // Calling `c.Fail(500, err)` is equivalent to:
 // ```
@@ -148,13 +392,13 @@ func (c *Ctx) StatusFunc() (func(int), bool) {
 	return c.Status, true
 }
 
-// Calls an HttpStatus in the current group by integer code from the Context,
-// if the status exists.
+// Calls an HttpStatus in the current group, or the nearest parent group, by
+// integer code from the Context, if the status exists.
 func (c *Ctx) Status(code int) {
-	if status, ok := c.group.HttpStatuses[code]; ok {
+	if status, ok := c.group.status(code); ok {
 		s := len(status.Handlers)
 		for i := 0; i < s; i++ {
-			status.Handlers[i](context.WithValue(CurrentContext, "Current", c))
+			status.Handlers[i](c.ctx)
 		}
 	}
 }
@@ -195,15 +439,18 @@ func (r *recorder) PostProcess(req *http.Request, rw ResponseWriter) {
 }
 
 func (r *recorder) Fmt() string {
-	return fmt.Sprintf("recorder	%s	%s	%s	%3d	%s	%s	%s", r.start, r.stop, r.latency, r.status, r.method, r.path, r.requester)
+	return fmt.Sprintf("recorder	%s	%s	%s	%3d	%s	%s	%s	%s", r.start, r.stop, r.latency, r.status, r.method, r.path, r.requester, r.id)
 }
 
+// LogFmt returns the recorded request as a colored TextFormatter line.
 func (r *recorder) LogFmt() string {
-	return fmt.Sprintf("%v |%s %3d %s| %12v | %s |%s %s %-7s %s",
-		r.stop.Format("2006/01/02 - 15:04:05"),
-		StatusColor(r.status), r.status, reset,
-		r.latency,
-		r.requester,
-		MethodColor(r.method), reset, r.method,
-		r.path)
+	return string(TextFormatter{}.Format(&LogEntry{
+		Time:      r.start,
+		Method:    r.method,
+		Path:      r.path,
+		Status:    r.status,
+		Latency:   r.latency,
+		Requester: r.requester,
+		RequestID: r.id,
+	}, true))
 }
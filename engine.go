@@ -1,18 +1,17 @@
 package engine
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 
 	"golang.org/x/net/context"
 )
 
-var (
-	CurrentContext context.Context
-)
-
 type (
 	// Manage is a function that can be registered to a route to handle HTTP
 	// requests. Like http.HandlerFunc, but takes a context.Context
@@ -20,13 +19,19 @@ type (
 
 	// Engine is the the core struct with groups, routing, signaling and more.
 	Engine struct {
-		trees map[string]*node
+		trees  map[string]*node
+		names  map[string]string
+		routes map[string]*Route
 		groups
 		*Group
-		cache   sync.Pool
-		Logger  *log.Logger
-		Signals Signals
-		Queues  queues
+		cache      sync.Pool
+		Logger     *log.Logger
+		RequestLog RequestLogger
+		TLSConfig  *tls.Config
+		Events     *EventBus
+		Metrics    *Metrics
+		queues     map[string]*Queue
+		queuesMu   sync.RWMutex
 		*conf
 	}
 )
@@ -44,9 +49,8 @@ func New(opts ...Conf) (engine *Engine, err error) {
 	engine.groups = make(groups)
 	engine.Group = NewGroup("/", engine)
 	engine.cache.New = engine.newCtx
-	// unsolved as to why this needs to be this high; anything lower results in lost messages during testing is the size in bytes
-	engine.Signals = make(Signals, 1000)
-	engine.Queues = engine.defaultqueues()
+	engine.Events = NewEventBus()
+	engine.defaultqueues()
 	err = engine.SetConf(opts...)
 	if err != nil {
 		return nil, err
@@ -64,8 +68,15 @@ func Basic(opts ...Conf) (engine *Engine, err error) {
 	return engine, nil
 }
 
-// Registers a new request Manage function with the given path and method.
-func (e *Engine) Manage(method string, path string, m Manage) {
+// Registers a new request Manage function with the given path and method, and
+// an optional name for building the path with URLFor.
+func (e *Engine) Manage(method string, path string, m Manage, name ...string) {
+	e.manage(&Route{Method: method, Path: path, Handler: handlerName(m)}, e.timed(m), name...)
+}
+
+func (e *Engine) manage(r *Route, m Manage, name ...string) {
+	method, path := r.Method, r.Path
+
 	if path[0] != '/' {
 		panic("path must begin with '/'")
 	}
@@ -80,26 +91,76 @@ func (e *Engine) Manage(method string, path string, m Manage) {
 		e.trees[method] = root
 	}
 
-	root.addRoute(path, m)
+	root.addRoute(path, func(c context.Context) {
+		if c != nil {
+			if curr, ok := c.Value(currentKey).(*Ctx); ok {
+				curr.route = path
+			}
+		}
+		m(c)
+	})
+
+	if len(name) > 0 {
+		r.Name = name[0]
+		e.name(r.Name, path)
+	}
+
+	if e.routes == nil {
+		e.routes = make(map[string]*Route)
+	}
+	e.routes[method+" "+path] = r
+	e.publish(EventRoute, method+" "+path, r)
+}
+
+func (e *Engine) name(name string, path string) {
+	if e.names == nil {
+		e.names = make(map[string]string)
+	}
+
+	if _, exists := e.names[name]; exists {
+		panic("a route is already registered with the name " + name)
+	}
+
+	e.names[name] = path
+}
+
+// URLFor returns the path of the route registered with the name, filling the
+// ':param' and '*catchAll' wildcards from params given as alternating key and
+// value strings, e.g. URLFor("user", "name", "gopher"). Values are path
+// escaped, a '*catchAll' value segment by segment. An error is returned for an
+// unknown route name or a missing parameter.
+func (e *Engine) URLFor(name string, params ...string) (string, error) {
+	path, ok := e.names[name]
+	if !ok {
+		return "", newError("no route named %s", name)
+	}
+	if len(params)%2 != 0 {
+		return "", newError("URLFor params must be key value pairs, received %d values", len(params))
+	}
+	values := make(map[string]string)
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+	return fillPath(path, values)
 }
 
 // Handler allows the usage of a http.Handler as request manage.
 func (e *Engine) Handler(method, path string, handler http.Handler) {
-	e.Manage(method, path,
-		func(c context.Context) {
+	e.manage(&Route{Method: method, Path: path, Handler: fmt.Sprintf("%T", handler)},
+		e.timed(func(c context.Context) {
 			curr := currentCtx(c)
 			handler.ServeHTTP(curr.RW, curr.request)
-		},
+		}),
 	)
 }
 
 // HandlerFunc allows the use of a http.HandlerFunc as request manage.
 func (e *Engine) HandlerFunc(method, path string, handler http.HandlerFunc) {
-	e.Manage(method, path,
-		func(c context.Context) {
+	e.manage(&Route{Method: method, Path: path, Handler: handlerName(handler)},
+		e.timed(func(c context.Context) {
 			curr := currentCtx(c)
 			handler(curr.RW, curr.request)
-		},
+		}),
 	)
 }
 
@@ -131,37 +192,100 @@ func (e *Engine) ServeFiles(path string, root http.FileSystem) {
 
 	fileServer := http.FileServer(root)
 
-	e.Manage("GET", path, func(c context.Context) {
+	e.manage(&Route{Method: "GET", Path: path, Handler: fmt.Sprintf("%T", fileServer)}, e.timed(func(c context.Context) {
 		curr := currentCtx(c)
 		curr.request.URL.Path = curr.Params.ByName("filepath")
 		fileServer.ServeHTTP(curr.RW, curr.request)
-	})
+	}))
 }
 
 func currentCtx(c context.Context) *Ctx {
-	return c.Value("Current").(*Ctx)
+	switch curr := c.(type) {
+	case *Ctx:
+		return curr
+	case *requestCtx:
+		return curr.current()
+	}
+	return c.Value(currentKey).(*Ctx)
 }
 
 // internal "recover"
 func (e *Engine) rcvr(c *Ctx) {
 	if rcv := recover(); rcv != nil {
-		p := newError(fmt.Sprintf("%s", rcv))
-		c.errorTyped(p, ErrorTypePanic, stack(3))
-		c.Status(500)
+		e.recovered(c, rcv, stack(3))
 	}
 }
 
+func (e *Engine) recovered(c *Ctx, rcv interface{}, stack []byte) {
+	p := newError(fmt.Sprintf("%s", rcv))
+	c.errorTyped(p, ErrorTypePanic, stack)
+	c.Status(500)
+	c.RW.WriteHeaderNow()
+}
+
 // internal "not found"
 func (e *Engine) ntfnd(c *Ctx) {
 	c.Status(404)
 }
 
+// internal "method not allowed"
+func (e *Engine) ntallwd(c *Ctx, allow string) {
+	c.RW.Header().Set("Allow", allow)
+	c.Status(405)
+}
+
+// internal "options"
+func (e *Engine) optns(c *Ctx, allow string) {
+	c.RW.Header().Set("Allow", allow)
+	c.RW.WriteHeader(204)
+	c.RW.WriteHeaderNow()
+}
+
+// allowed returns a comma separated list of methods, other than the provided
+// method, with a Manage registered for the path. OPTIONS is included when
+// AutoOptions is enabled, and HEAD for a GET Manage when ImplicitHead is.
+func (e *Engine) allowed(path, method string) string {
+	var allow []string
+	options, get, head := false, false, false
+	for m, root := range e.trees {
+		if m == method {
+			continue
+		}
+		if manage, _, _ := root.getValue(path); manage != nil {
+			allow = append(allow, m)
+			options = options || m == "OPTIONS"
+			get = get || m == "GET"
+			head = head || m == "HEAD"
+		}
+	}
+	if get && e.ImplicitHead && !head && method != "HEAD" {
+		allow = append(allow, "HEAD")
+	}
+	if len(allow) > 0 && e.AutoOptions && !options {
+		allow = append(allow, "OPTIONS")
+	}
+	sort.Strings(allow)
+	return strings.Join(allow, ", ")
+}
+
+// groupFor returns the group with the longest prefix containing the path.
+func (e *Engine) groupFor(path string) *Group {
+	group := e.groups["/"]
+	for prefix, g := range e.groups {
+		if len(prefix) > len(group.prefix) && strings.HasPrefix(path, prefix) &&
+			(len(path) == len(prefix) || path[len(prefix)] == '/' || prefix[len(prefix)-1] == '/') {
+			group = g
+		}
+	}
+	return group
+}
+
 // internal "servehttp"
 func (engine *Engine) srvhttp(w http.ResponseWriter, req *http.Request, c context.Context) {
 	curr := currentCtx(c)
 	defer engine.rcvr(curr)
+	path := req.URL.Path
 	if root := engine.trees[req.Method]; root != nil {
-		path := req.URL.Path
 		if manage, ps, tsr := root.getValue(path); manage != nil {
 			curr.Params = ps
 			manage(c)
@@ -199,21 +323,71 @@ func (engine *Engine) srvhttp(w http.ResponseWriter, req *http.Request, c contex
 		}
 	}
 
+	if req.Method == "HEAD" && engine.ImplicitHead {
+		if root := engine.trees["GET"]; root != nil {
+			if manage, ps, _ := root.getValue(path); manage != nil {
+				hw := newHeadResponseWriter(curr.RW)
+				curr.RW = hw
+				curr.Params = ps
+				manage(c)
+				hw.WriteHeaderNow()
+				return
+			}
+		}
+	}
+
+	curr.group = engine.groupFor(path)
+
+	if req.Method == "OPTIONS" && engine.AutoOptions {
+		if allow := engine.allowed(path, req.Method); allow != "" {
+			engine.optns(curr, allow)
+			return
+		}
+	} else if engine.MethodNotAllowed {
+		if allow := engine.allowed(path, req.Method); allow != "" {
+			engine.ntallwd(curr, allow)
+			return
+		}
+	}
+
 	engine.ntfnd(curr)
 	return
 }
 
-// ServeHTTP makes the engine implement the http.Handler interface.
+// ServeHTTP makes the engine implement the http.Handler interface. Each request
+// is served with a context.Context carrying its Ctx, derived from the request
+// context and cancelled when the request is served.
 func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	ctx := engine.getCtx(w, req)
-	CurrentContext, cancel := context.WithCancel(context.WithValue(context.Background(), "Current", ctx))
-	engine.srvhttp(w, req, CurrentContext)
-	engine.putCtx(currentCtx(CurrentContext))
+	ctx, cancel := context.WithCancel(req.Context())
+	c := engine.getCtx(w, req, ctx)
+	engine.srvhttp(w, req, c.ctx)
 	cancel()
+	engine.putCtx(c)
 }
 
+// Run serves the engine on the address with a Server, until shut down by
+// SIGTERM or SIGINT, panicking on any error listening or serving. A shutdown
+// exceeding the GracePeriod is logged.
 func (engine *Engine) Run(addr string) {
-	if err := http.ListenAndServe(addr, engine); err != nil {
+	engine.ran(NewServer(addr, engine).ListenAndServe())
+}
+
+// RunTLS serves the engine over TLS on the address with a Server, using the
+// certificate and key files, until shut down by SIGTERM or SIGINT, panicking
+// on any error listening or serving. A shutdown exceeding the GracePeriod is
+// logged.
+func (engine *Engine) RunTLS(addr string, certFile string, keyFile string) {
+	engine.ran(NewServer(addr, engine).ListenAndServeTLS(certFile, keyFile))
+}
+
+// ran returns from Run or RunTLS after the Server shut down, logging a shutdown
+// exceeding the GracePeriod, and panicking on any other error.
+func (engine *Engine) ran(err error) {
+	switch err {
+	case nil:
+	case context.DeadlineExceeded:
+		log.Println(fmt.Errorf("[ENGINE] shutdown exceeded the grace period of %s", engine.GracePeriod))
+	default:
 		panic(err)
 	}
 }
@@ -2,10 +2,13 @@ package engine
 
 import (
 	"errors"
+	"fmt"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	"golang.org/x/net/context"
@@ -22,7 +25,7 @@ func methodNotMethod(method string) string {
 	methods := []string{"GET", "POST", "PATCH", "DELETE", "PUT", "OPTIONS", "HEAD"}
 	newmethod := methods[rand.Intn(len(methods))]
 	if newmethod == method {
-		methodNotMethod(newmethod)
+		return methodNotMethod(method)
 	}
 	return newmethod
 }
@@ -114,8 +117,11 @@ func testRouteNotOK(method string, t *testing.T) {
 	if passed == true {
 		t.Errorf(method + " route handler was invoked, when it should not")
 	}
-	if w.Code != http.StatusNotFound {
-		t.Errorf("Status code should be %v, was %d. Location: %s", http.StatusNotFound, w.Code, w.HeaderMap.Get("Location"))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Status code should be %v, was %d. Location: %s", http.StatusMethodNotAllowed, w.Code, w.HeaderMap.Get("Location"))
+	}
+	if allow := w.HeaderMap.Get("Allow"); allow != othermethod {
+		t.Errorf("Allow header should be %s, was %s", othermethod, allow)
 	}
 }
 
@@ -128,6 +134,96 @@ func TestRouteNotOK(t *testing.T) {
 	testRouteNotOK("HEAD", t)
 }
 
+func TestMethodNotAllowed(t *testing.T) {
+	e, _ := Basic()
+	e.Take("/test_allow", "PUT", func(c context.Context) {})
+	e.Take("/test_allow", "GET", func(c context.Context) {})
+	e.Take("/test_allow", "DELETE", func(c context.Context) {})
+	w := PerformRequest(e, "POST", "/test_allow")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Status code should be %v, was %d", http.StatusMethodNotAllowed, w.Code)
+	}
+	if allow := w.HeaderMap.Get("Allow"); allow != "DELETE, GET, PUT" {
+		t.Errorf("Allow header should be `DELETE, GET, PUT`, was `%s`", allow)
+	}
+
+	e, _ = Basic(MethodNotAllowed(false))
+	e.Take("/test_allow", "GET", func(c context.Context) {})
+	w = PerformRequest(e, "POST", "/test_allow")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Status code should be %v, was %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestAutoOptions(t *testing.T) {
+	e, _ := Basic(AutoOptions(true))
+	e.Take("/test_options", "GET", func(c context.Context) {})
+	e.Take("/test_options", "POST", func(c context.Context) {})
+	w := PerformRequest(e, "OPTIONS", "/test_options")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Status code should be %v, was %d", http.StatusNoContent, w.Code)
+	}
+	if allow := w.HeaderMap.Get("Allow"); allow != "GET, OPTIONS, POST" {
+		t.Errorf("Allow header should be `GET, OPTIONS, POST`, was `%s`", allow)
+	}
+
+	w = PerformRequest(e, "OPTIONS", "/test_options_missing")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Status code should be %v, was %d", http.StatusNotFound, w.Code)
+	}
+
+	passed := false
+	e.Take("/test_options", "OPTIONS", func(c context.Context) { passed = true })
+	w = PerformRequest(e, "OPTIONS", "/test_options")
+	if !passed {
+		t.Errorf("registered OPTIONS route handler was not invoked.")
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("Status code should be %v, was %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestImplicitHead(t *testing.T) {
+	e, _ := Basic(ImplicitHead(true))
+	e.Take("/test_head", "GET", func(c context.Context) {
+		curr := currentCtx(c)
+		curr.RW.Header().Set("X-Test", "HEAD")
+		curr.RW.WriteHeader(202)
+		curr.RW.Write([]byte("discarded"))
+	})
+	w := PerformRequest(e, "HEAD", "/test_head")
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Status code should be %v, was %d", http.StatusAccepted, w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Body should be empty, was `%s`", w.Body.String())
+	}
+	if h := w.HeaderMap.Get("X-Test"); h != "HEAD" {
+		t.Errorf("X-Test header should be HEAD, was `%s`", h)
+	}
+	if cl := w.HeaderMap.Get("Content-Length"); cl != "9" {
+		t.Errorf("Content-Length header should be 9, was `%s`", cl)
+	}
+	w = PerformRequest(e, "POST", "/test_head")
+	if allow := w.HeaderMap.Get("Allow"); w.Code != http.StatusMethodNotAllowed || allow != "GET, HEAD" {
+		t.Errorf("Allow header should be `GET, HEAD`, was %d `%s`", w.Code, allow)
+	}
+
+	e, _ = Basic(ImplicitHead(true), AutoOptions(true))
+	e.Take("/test_head", "GET", func(c context.Context) {})
+	w = PerformRequest(e, "OPTIONS", "/test_head")
+	if allow := w.HeaderMap.Get("Allow"); allow != "GET, HEAD, OPTIONS" {
+		t.Errorf("Allow header should be `GET, HEAD, OPTIONS`, was `%s`", allow)
+	}
+
+	e, _ = Basic()
+	e.Take("/test_head", "GET", func(c context.Context) {})
+	w = PerformRequest(e, "HEAD", "/test_head")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Status code should be %v, was %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
 type mockResponseWriter struct{}
 
 func (m *mockResponseWriter) Header() (h http.Header) {
@@ -367,3 +463,248 @@ func TestMiddleware(t *testing.T) {
 	testMiddleware("OPTIONS", t)
 	testMiddleware("HEAD", t)
 }
+
+func TestMiddlewareChain(t *testing.T) {
+	var order []string
+
+	e, _ := Basic()
+	g := e.New("/chain")
+
+	e.Use(func(c context.Context) {
+		order = append(order, "root-before")
+		currentCtx(c).Next()
+		order = append(order, "root-after")
+	})
+	g.Use(func(c context.Context) { order = append(order, "group") })
+	g.Take("/test", "GET", func(c context.Context) { order = append(order, "handler") })
+
+	PerformRequest(e, "GET", "/chain/test")
+
+	expected := []string{"root-before", "group", "handler", "root-after"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Middleware order should be %v, was %v", expected, order)
+	}
+}
+
+func TestMiddlewareAbort(t *testing.T) {
+	passed := false
+
+	e, _ := Basic()
+	e.Use(func(c context.Context) { currentCtx(c).Abort(401) })
+	e.Take("/test_abort", "GET", func(c context.Context) { passed = true })
+
+	w := PerformRequest(e, "GET", "/test_abort")
+
+	if passed == true {
+		t.Errorf("route handler was invoked after middleware aborted")
+	}
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Status code should be %v, was %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestURLFor(t *testing.T) {
+	e, _ := Basic()
+	g := e.New("/api")
+	g.Take("/user/:name/:id", "GET", func(c context.Context) {}, "user")
+	e.Manage("GET", "/src/*filepath", func(c context.Context) {}, "src")
+	e.Take("/static", "GET", func(c context.Context) {}, "static")
+	e.Take("/item/:id{int}", "GET", func(c context.Context) {}, "item")
+
+	urls := []struct {
+		name   string
+		params []string
+		url    string
+	}{
+		{"user", []string{"name", "gopher", "id", "7"}, "/api/user/gopher/7"},
+		{"src", []string{"filepath", "/js/app.js"}, "/src/js/app.js"},
+		{"src", []string{"filepath", "js/app.js"}, "/src/js/app.js"},
+		{"static", nil, "/static"},
+		{"item", []string{"id", "42"}, "/item/42"},
+		{"user", []string{"name", "a b?c#d%", "id", "7"}, "/api/user/a%20b%3Fc%23d%25/7"},
+		{"src", []string{"filepath", "/my dir/a?b.js"}, "/src/my%20dir/a%3Fb.js"},
+	}
+	for _, u := range urls {
+		url, err := e.URLFor(u.name, u.params...)
+		if err != nil {
+			t.Errorf("URLFor %s returned error: %s", u.name, err)
+		}
+		if url != u.url {
+			t.Errorf("URLFor %s should be %s, was %s", u.name, u.url, url)
+		}
+	}
+
+	errs := [][]string{
+		{"missing"},
+		{"user", "name", "gopher"},
+		{"user", "name", "gopher", "id"},
+		{"user", "name", "go/pher", "id", "7"},
+		{"item", "id", "forty-two"},
+	}
+	for _, args := range errs {
+		if _, err := e.URLFor(args[0], args[1:]...); err == nil {
+			t.Errorf("URLFor %v should return an error", args)
+		}
+	}
+
+	recv := catchPanic(func() {
+		e.Take("/other", "GET", func(c context.Context) {}, "static")
+	})
+	if recv == nil {
+		t.Error("registering a duplicate route name did not panic")
+	}
+}
+
+func routesHandler(c context.Context) {}
+
+func TestRoutes(t *testing.T) {
+	e, _ := Basic()
+	g := e.New("/api")
+	g.Take("/user/:name", "GET", routesHandler, "user")
+	g.Take("/user/:name", "DELETE", routesHandler)
+	e.Take("/", "GET", routesHandler)
+	e.ServeFiles("/static/*filepath", http.Dir("."))
+
+	expected := []Route{
+		{"GET", "/", "/", "engine.routesHandler", ""},
+		{"DELETE", "/api/user/:name", "/api", "engine.routesHandler", ""},
+		{"GET", "/api/user/:name", "/api", "engine.routesHandler", "user"},
+		{"GET", "/static/*filepath", "", "*http.fileHandler", ""},
+	}
+
+	routes := e.Routes()
+	if len(routes) != len(expected) {
+		t.Fatalf("Routes should return %d routes, returned %d: %+v", len(expected), len(routes), routes)
+	}
+	for i, r := range routes {
+		want := expected[i]
+		if r.Method != want.Method || r.Path != want.Path || r.Group != want.Group || r.Name != want.Name {
+			t.Errorf("Route %d should be %+v, was %+v", i, want, r)
+		}
+		if !strings.HasSuffix(r.Handler, want.Handler) {
+			t.Errorf("Route %d handler should be %s, was %s", i, want.Handler, r.Handler)
+		}
+	}
+}
+
+type testKey struct{}
+
+func TestCtxContext(t *testing.T) {
+	e, _ := New()
+	var done bool
+	e.Take("/ctx", "GET", func(c context.Context) {
+		curr, ok := c.Value(currentKey).(*Ctx)
+		if !ok {
+			t.Fatalf("Manage should receive a context.Context carrying the Ctx, received %T", c)
+		}
+		if c.Value(testKey{}) != "request" || curr.Value(testKey{}) != "request" {
+			t.Errorf("Ctx should carry the values of the request context")
+		}
+		select {
+		case <-curr.Done():
+			done = true
+		default:
+		}
+	})
+
+	rctx, cancel := context.WithCancel(context.WithValue(context.Background(), testKey{}, "request"))
+	req, _ := http.NewRequest("GET", "/ctx", nil)
+	req = req.WithContext(rctx)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	if done {
+		t.Errorf("Ctx should not be done before the request context is cancelled")
+	}
+
+	cancel()
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	if !done {
+		t.Errorf("Ctx should be done once the request context is cancelled")
+	}
+}
+
+func TestCtxContextEscape(t *testing.T) {
+	e, _ := New()
+	var kept context.Context
+	e.Take("/keep", "GET", func(c context.Context) {
+		kept, _ = context.WithCancel(c)
+	})
+	e.Take("/other", "GET", func(c context.Context) {})
+
+	req, _ := http.NewRequest("GET", "/keep", nil)
+	req = req.WithContext(context.WithValue(context.Background(), testKey{}, "request"))
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	PerformRequest(e, "GET", "/other")
+
+	if _, ok := kept.Deadline(); ok {
+		t.Errorf("A kept context.Context should have no deadline")
+	}
+	if kept.Err() != context.Canceled {
+		t.Errorf("A kept context.Context should be cancelled once the request is served, was %v", kept.Err())
+	}
+	if curr := kept.Value(currentKey); curr != nil {
+		t.Errorf("A kept context.Context should not carry a reused Ctx, carried %v", curr)
+	}
+	if kept.Value(testKey{}) != "request" {
+		t.Errorf("A kept context.Context should carry the values of the request context")
+	}
+}
+
+func TestConcurrentStatus(t *testing.T) {
+	e, _ := New()
+	e.HttpStatuses[418].Update(func(c context.Context) {
+		curr := currentCtx(c)
+		curr.RW.Write([]byte(curr.Params.ByName("n")))
+	})
+	e.Take("/teapot/:n", "GET", func(c context.Context) { currentCtx(c).Status(418) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(n string) {
+			defer wg.Done()
+			w := PerformRequest(e, "GET", "/teapot/"+n)
+			if w.Code != 418 || w.Body.String() != n {
+				t.Errorf("Concurrent request %s should be served its own status, was %d '%s'", n, w.Code, w.Body.String())
+			}
+		}(fmt.Sprint(i))
+	}
+	wg.Wait()
+}
+
+func TestCtxKeys(t *testing.T) {
+	e, _ := New()
+	e.Use(func(c context.Context) {
+		curr := currentCtx(c)
+		if _, exists := curr.Get("user"); exists {
+			t.Errorf("Ctx values should not persist between requests")
+		}
+		curr.Set("user", "gopher")
+		curr.Set("admin", true)
+		curr.Set("id", 7)
+		curr.Next()
+	})
+	e.Take("/keys", "GET", func(c context.Context) {
+		curr := currentCtx(c)
+		if c.Value("Current") != nil {
+			t.Errorf("Ctx should not be stored under a string key")
+		}
+		if curr.MustGet("user") != "gopher" || curr.GetString("user") != "gopher" {
+			t.Errorf("user should be gopher, was %v", curr.MustGet("user"))
+		}
+		if !curr.GetBool("admin") || curr.GetInt("id") != 7 || curr.GetInt64("id") != 0 {
+			t.Errorf("typed getters returned admin %t, id %d", curr.GetBool("admin"), curr.GetInt("id"))
+		}
+		if v, exists := curr.Get("missing"); exists || v != nil {
+			t.Errorf("missing key should not exist")
+		}
+		if recv := catchPanic(func() { curr.MustGet("missing") }); recv == nil {
+			t.Errorf("MustGet should panic for a missing key")
+		}
+	})
+
+	for i := 0; i < 2; i++ {
+		if w := PerformRequest(e, "GET", "/keys"); w.Code != 200 {
+			t.Errorf("Status code should be 200, was %d", w.Code)
+		}
+	}
+}
@@ -8,10 +8,11 @@ import (
 )
 
 const (
-	ErrorTypeInternal = 1 << iota
-	ErrorTypeExternal = 1 << iota
-	ErrorTypePanic    = 1 << iota
-	ErrorTypeAll      = 0xffffffff
+	ErrorTypeInternal   = 1 << iota
+	ErrorTypeExternal   = 1 << iota
+	ErrorTypePanic      = 1 << iota
+	ErrorTypeValidation = 1 << iota
+	ErrorTypeAll        = 0xffffffff
 )
 
 var (
@@ -24,9 +25,10 @@ var (
 type (
 	// Used with Ctx to collect errors that occurred during a http request.
 	errorMsg struct {
-		Type uint32      `json:"-"`
-		Err  string      `json:"error"`
-		Meta interface{} `json:"meta"`
+		Type      uint32      `json:"-"`
+		Err       string      `json:"error"`
+		Meta      interface{} `json:"meta"`
+		RequestID string      `json:"request_id,omitempty"`
 	}
 
 	errorMsgs []errorMsg
@@ -66,6 +68,9 @@ func (a errorMsgs) String() string {
 	buffer.WriteString("[Engine] Errors\n")
 	for i, msg := range a {
 		text := fmt.Sprintf("#%02d: %s\n%s\n", (i + 1), msg.Err, msg.Meta)
+		if msg.RequestID != "" {
+			text = fmt.Sprintf("#%02d: [%s] %s\n%s\n", (i + 1), msg.RequestID, msg.Err, msg.Meta)
+		}
 		buffer.WriteString(text)
 	}
 	return buffer.String()
@@ -0,0 +1,241 @@
+package engine
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// EventMessage is a message sent to the engine without a queue.
+	EventMessage EventKind = iota
+	// EventRequest is a served request, with the *LogEntry as Data.
+	EventRequest
+	// EventPanic is a recovered panic, with the error and stack as Message.
+	EventPanic
+	// EventRoute is a registered route, with the *Route as Data.
+	EventRoute
+	// EventServer is a Server lifecycle event, e.g. server-start.
+	EventServer
+	// EventShutdown is a Server shutting down.
+	EventShutdown
+)
+
+const (
+	// Drop discards a new event when a subscription buffer is full.
+	Drop Overflow = iota
+	// DropOldest discards the oldest buffered event to make room for a new
+	// event when a subscription buffer is full.
+	DropOldest
+	// Block waits for room in a full subscription buffer, until the
+	// subscription is closed.
+	Block
+)
+
+var eventKinds = map[EventKind]string{
+	EventMessage:  "message",
+	EventRequest:  "request-complete",
+	EventPanic:    "panic",
+	EventRoute:    "route-registered",
+	EventServer:   "server",
+	EventShutdown: "shutdown",
+}
+
+type (
+	// EventKind is the kind of an Event.
+	EventKind int
+
+	// Overflow is the policy of a Subscription with a full buffer.
+	Overflow int
+
+	// Event is published to an EventBus, with a message and any data for the
+	// kind of event.
+	Event struct {
+		Kind    EventKind
+		Time    time.Time
+		Message string
+		Data    interface{}
+	}
+
+	// EventBus publishes events to every Subscription for the event kind.
+	EventBus struct {
+		mu     sync.RWMutex
+		subs   map[*Subscription]struct{}
+		closed bool
+	}
+
+	// Subscription receives published events of its kinds on C, until
+	// unsubscribed or the EventBus is closed, when C is closed.
+	Subscription struct {
+		dropped  uint64
+		C        <-chan Event
+		c        chan Event
+		bus      *EventBus
+		kinds    map[EventKind]bool
+		overflow Overflow
+		mu       sync.RWMutex
+		done     chan struct{}
+		once     sync.Once
+		closed   bool
+	}
+)
+
+func (k EventKind) String() string {
+	if s, ok := eventKinds[k]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// NewEventBus returns an empty, open EventBus.
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns a Subscription to events of the kinds, or every kind
+// without any, buffering up to size events with the overflow policy. With no
+// buffer, an event is delivered to a waiting reader, or the policy applied. On
+// a closed EventBus, the Subscription is returned closed.
+func (b *EventBus) Subscribe(size int, overflow Overflow, kinds ...EventKind) *Subscription {
+	c := make(chan Event, size)
+	s := &Subscription{
+		C:        c,
+		c:        c,
+		bus:      b,
+		overflow: overflow,
+		done:     make(chan struct{}),
+	}
+	if len(kinds) > 0 {
+		s.kinds = make(map[EventKind]bool)
+		for _, k := range kinds {
+			s.kinds[k] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		s.close()
+	} else {
+		b.subs[s] = struct{}{}
+	}
+	return s
+}
+
+// Publish sends the event to every Subscription for its kind, setting the
+// event Time if unset. Publishing to a nil or closed EventBus does nothing.
+func (b *EventBus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.RLock()
+	subs := make([]*Subscription, 0, len(b.subs))
+	for s := range b.subs {
+		if s.kinds == nil || s.kinds[e.Kind] {
+			subs = append(subs, s)
+		}
+	}
+	b.mu.RUnlock()
+	for _, s := range subs {
+		s.send(e)
+	}
+}
+
+// subscribed reports whether any Subscription receives events of the kind.
+func (b *EventBus) subscribed(kind EventKind) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for s := range b.subs {
+		if s.kinds == nil || s.kinds[kind] {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes every Subscription, and ignores any later Publish.
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	b.closed = true
+	subs := b.subs
+	b.subs = make(map[*Subscription]struct{})
+	b.mu.Unlock()
+	for s := range subs {
+		s.close()
+	}
+}
+
+func (s *Subscription) send(e Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	switch s.overflow {
+	case Block:
+		select {
+		case s.c <- e:
+		case <-s.done:
+		}
+	case DropOldest:
+		for {
+			select {
+			case s.c <- e:
+				return
+			default:
+			}
+			if cap(s.c) == 0 {
+				// without a buffer there is no older event to drop, and the
+				// event is dropped unless a reader is waiting
+				atomic.AddUint64(&s.dropped, 1)
+				return
+			}
+			select {
+			case <-s.c:
+				atomic.AddUint64(&s.dropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case s.c <- e:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+// Dropped returns the number of events dropped by the overflow policy.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Unsubscribe removes the Subscription from the EventBus and closes C, once
+// any Publish blocked on the Subscription has returned.
+func (s *Subscription) Unsubscribe() {
+	s.bus.mu.Lock()
+	delete(s.bus.subs, s)
+	s.bus.mu.Unlock()
+	s.close()
+}
+
+func (s *Subscription) close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.mu.Lock()
+		s.closed = true
+		close(s.c)
+		s.mu.Unlock()
+	})
+}
+
+// publish publishes an event of the kind to the engine Events.
+func (e *Engine) publish(kind EventKind, message string, data interface{}) {
+	e.Events.Publish(Event{Kind: kind, Message: message, Data: data})
+}
@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func received(s *Subscription) []Event {
+	var events []Event
+	for {
+		select {
+		case e, ok := <-s.C:
+			if !ok {
+				return events
+			}
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestEventBusKinds(t *testing.T) {
+	b := NewEventBus()
+	all := b.Subscribe(10, Drop)
+	panics := b.Subscribe(10, Drop, EventPanic)
+	panics2 := b.Subscribe(10, Drop, EventPanic)
+
+	b.Publish(Event{Kind: EventMessage, Message: "message"})
+	b.Publish(Event{Kind: EventPanic, Message: "panic"})
+
+	if events := received(all); len(events) != 2 {
+		t.Errorf("Subscription to every kind should receive 2 events, received %d", len(events))
+	}
+	for _, s := range []*Subscription{panics, panics2} {
+		events := received(s)
+		if len(events) != 1 || events[0].Message != "panic" || events[0].Time.IsZero() {
+			t.Errorf("Subscription to EventPanic should receive the panic, received %v", events)
+		}
+	}
+}
+
+func TestEventBusOverflow(t *testing.T) {
+	b := NewEventBus()
+	drop := b.Subscribe(2, Drop)
+	oldest := b.Subscribe(2, DropOldest)
+	for _, m := range []string{"1", "2", "3", "4"} {
+		b.Publish(Event{Message: m})
+	}
+
+	if events := received(drop); len(events) != 2 || events[0].Message != "1" || events[1].Message != "2" || drop.Dropped() != 2 {
+		t.Errorf("Drop should keep the first events, kept %v and dropped %d", events, drop.Dropped())
+	}
+	if events := received(oldest); len(events) != 2 || events[0].Message != "3" || events[1].Message != "4" || oldest.Dropped() != 2 {
+		t.Errorf("DropOldest should keep the last events, kept %v and dropped %d", events, oldest.Dropped())
+	}
+
+	block := b.Subscribe(1, Block)
+	b.Publish(Event{Message: "buffered"})
+	published := make(chan struct{})
+	go func() {
+		b.Publish(Event{Message: "blocked"})
+		close(published)
+	}()
+	select {
+	case <-published:
+		t.Errorf("Block should wait for room in the buffer")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if e := <-block.C; e.Message != "buffered" {
+		t.Errorf("Block should deliver the buffered event first, delivered %s", e.Message)
+	}
+	<-published
+	if e := <-block.C; e.Message != "blocked" {
+		t.Errorf("Block should deliver the blocked event, delivered %s", e.Message)
+	}
+}
+
+func TestEventBusUnbuffered(t *testing.T) {
+	b := NewEventBus()
+	s := b.Subscribe(0, DropOldest)
+	delivered := make(chan Event, 1)
+	go func() { delivered <- <-s.C }()
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		b.Publish(Event{Message: "waited"})
+		select {
+		case e := <-delivered:
+			if e.Message != "waited" {
+				t.Errorf("DropOldest without a buffer delivered %s", e.Message)
+			}
+			return
+		case <-time.After(time.Millisecond):
+		}
+	}
+	t.Errorf("DropOldest without a buffer should deliver to a waiting reader, dropped %d", s.Dropped())
+}
+
+func TestEventBusLifecycle(t *testing.T) {
+	b := NewEventBus()
+	block := b.Subscribe(0, Block)
+	published := make(chan struct{})
+	go func() {
+		b.Publish(Event{Message: "blocked"})
+		close(published)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	block.Unsubscribe()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatalf("Unsubscribe should release a blocked Publish")
+	}
+	if _, ok := <-block.C; ok {
+		t.Errorf("Unsubscribe should close C")
+	}
+	block.Unsubscribe()
+
+	s := b.Subscribe(10, Drop)
+	b.Close()
+	b.Publish(Event{Message: "closed"})
+	if events := received(s); len(events) != 0 {
+		t.Errorf("Close should close every Subscription, received %v", events)
+	}
+	if _, ok := <-b.Subscribe(10, Drop).C; ok {
+		t.Errorf("Subscribe to a closed EventBus should return a closed Subscription")
+	}
+}
+
+func TestEngineEvents(t *testing.T) {
+	e, _ := New()
+	s := e.Events.Subscribe(10, Drop, EventRoute, EventRequest)
+	e.Take("/events/:id", "GET", func(c context.Context) {})
+	PerformRequest(e, "GET", "/events/1")
+	s.Unsubscribe()
+
+	events := received(s)
+	if len(events) != 2 {
+		t.Fatalf("Engine should publish 2 events, published %v", events)
+	}
+	if r, ok := events[0].Data.(*Route); events[0].Kind != EventRoute || !ok || r.Path != "/events/:id" {
+		t.Errorf("Engine should publish the registered route, published %+v", events[0])
+	}
+	if entry, ok := events[1].Data.(*LogEntry); events[1].Kind != EventRequest || !ok || entry.Route != "/events/:id" {
+		t.Errorf("Engine should publish the completed request, published %+v", events[1])
+	}
+}
@@ -3,6 +3,7 @@ package engine
 import (
 	"net/http"
 	"path/filepath"
+	"time"
 
 	"golang.org/x/net/context"
 )
@@ -14,9 +15,20 @@ type (
 		prefix     string
 		parent     *Group
 		engine     *Engine
-		middleware []http.HandlerFunc
+		middleware []Manage
+		chains     []*chain
+		templates  *Templates
+		timeout    time.Duration
+		timed      bool
 		HttpStatuses
 	}
+
+	// chain is a Manage taken by a Group, preceded by the middleware of the
+	// group and all its parents.
+	chain struct {
+		manage   Manage
+		handlers []Manage
+	}
 )
 
 func (group *Group) pathFor(path string) string {
@@ -28,55 +40,139 @@ func (group *Group) pathFor(path string) string {
 	return joined
 }
 
-// NewGroup creates a group with no parent and the provided prefix.
+// NewGroup creates a group with no parent and the provided prefix, using the
+// default HttpStatuses.
 func NewGroup(prefix string, engine *Engine) *Group {
+	return newGroup(prefix, nil, engine)
+}
+
+func newGroup(prefix string, parent *Group, engine *Engine) *Group {
 	if group, exists := engine.groups[prefix]; exists {
 		return group
-	} else {
-		newgroup := &Group{prefix: prefix,
-			engine:       engine,
-			HttpStatuses: defaultHttpStatuses()}
-		engine.groups[prefix] = newgroup
-		return newgroup
 	}
+	newgroup := &Group{prefix: prefix,
+		parent:       parent,
+		engine:       engine,
+		HttpStatuses: make(HttpStatuses)}
+	if parent == nil {
+		newgroup.HttpStatuses = defaultHttpStatuses()
+	}
+	engine.groups[prefix] = newgroup
+	return newgroup
 }
 
 // New creates a group from an existing group using the the groups prefix and
 // the provided component string as a prefix. The existing group will be the
-// parent of the new group.
+// parent of the new group, and the new group inherits the middleware and
+// HttpStatuses of its parent.
 func (group *Group) New(component string) *Group {
 	prefix := group.pathFor(component)
-	newgroup := NewGroup(prefix, group.engine)
+	newgroup := newGroup(prefix, group, group.engine)
 	newgroup.parent = group
 	return newgroup
 }
 
+// status returns the HttpStatus for the code from the group, or the nearest
+// parent group with the code.
+func (group *Group) status(code int) (*HttpStatus, bool) {
+	for g := group; g != nil; g = g.parent {
+		if s, ok := g.HttpStatuses[code]; ok {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// Middleware adds http.HandlerFunc middleware to the group. These run in
+// order before the handler, and cannot halt the chain.
 func (group *Group) Middleware(h ...http.HandlerFunc) {
-	group.middleware = append(group.middleware, h...)
+	for _, fn := range h {
+		group.Use(handlerFuncManage(fn))
+	}
+}
+
+// Use adds Manage middleware to the group. Middleware run after the middleware
+// of any parent groups, and may call Ctx.Next to run the remainder of the chain
+// (running code after the handler) or Ctx.Abort to halt the chain.
+func (group *Group) Use(m ...Manage) {
+	group.middleware = append(group.middleware, m...)
+	for _, g := range group.engine.groups {
+		if g.descends(group) {
+			g.rechain()
+		}
+	}
+}
+
+func handlerFuncManage(fn http.HandlerFunc) Manage {
+	return func(c context.Context) {
+		curr := currentCtx(c)
+		fn(curr.RW, curr.request)
+	}
+}
+
+// descends reports whether the group is, or is a descendant of, the provided
+// group.
+func (group *Group) descends(from *Group) bool {
+	for g := group; g != nil; g = g.parent {
+		if g == from {
+			return true
+		}
+	}
+	return false
+}
+
+// handlers returns the middleware of the group and all its parents, outermost
+// first.
+func (group *Group) handlers() []Manage {
+	var h []Manage
+	if group.parent != nil {
+		h = group.parent.handlers()
+	}
+	return append(h, group.middleware...)
+}
+
+func (group *Group) newChain(m Manage) *chain {
+	ch := &chain{manage: m}
+	ch.handlers = append(group.handlers(), m)
+	group.chains = append(group.chains, ch)
+	return ch
 }
 
-func (group *Group) events(c *Ctx) {
-	for _, h := range group.middleware {
-		h(c.RW, c.request)
+func (group *Group) rechain() {
+	for _, ch := range group.chains {
+		ch.handlers = append(group.handlers(), ch.manage)
 	}
 }
 
-// Handle provides a route, method, and Manage to the router, and creates
-// a function using the handler when the router matches the route and method.
-func (group *Group) Take(route string, method string, handler func(context.Context)) {
-	group.engine.Manage(method, group.pathFor(route), func(c context.Context) {
+// Take provides a route, method, Manage, and optional route name to the
+// router, and creates a function using the handler, preceded by the middleware
+// chain of the group, when the router matches the route and method. The chain
+// runs under the timeout of the group, if any.
+func (group *Group) Take(route string, method string, handler func(context.Context), name ...string) {
+	ch := group.newChain(handler)
+	r := &Route{Method: method,
+		Path:    group.pathFor(route),
+		Group:   group.prefix,
+		Handler: handlerName(handler)}
+	group.engine.manage(r, func(c context.Context) {
 		curr := currentCtx(c)
 		curr.group = group
-		group.events(curr)
-		handler(context.WithValue(c, "Current", curr))
-	})
+		curr.serve(c, ch.handlers)
+	}, name...)
 }
 
+// TakeStatus adds a Manage to the HttpStatus for the code. A group without
+// its own HttpStatus for the code gets a new one, overriding any inherited
+// from a parent group.
 func (group *Group) TakeStatus(code int, statushandler func(context.Context)) {
 	if ss, ok := group.HttpStatuses[code]; ok {
 		ss.Update(statushandler)
 	} else {
-		ns := NewHttpStatus(code, string(code))
+		message := http.StatusText(code)
+		if ps, ok := group.status(code); ok {
+			message = ps.Message
+		}
+		ns := NewHttpStatus(code, message)
 		ns.Update(statushandler)
 		group.HttpStatuses.New(ns)
 	}
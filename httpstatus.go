@@ -3,7 +3,9 @@ package engine
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"html"
 	"net/http"
 
 	"golang.org/x/net/context"
@@ -14,6 +16,13 @@ const (
 <title>%d %s</title>
 <h1>%s</h1>
 <p>%s</p>
+`
+	validationHtml = `<!DOCTYPE HTML>
+<title>%d %s</title>
+<h1>%s</h1>
+<p>%s</p>
+<ul>
+%s</ul>
 `
 	panicBlock = `<h1>%s</h1>
 <pre style="font-weight: bold;">%s</pre>
@@ -60,6 +69,22 @@ type (
 
 	// A map of HttpStatus instances, keyed by status code
 	HttpStatuses map[int]*HttpStatus
+
+	// Problem is a json problem document, in the style of RFC 7807, served for
+	// a HttpStatus in place of a html page.
+	Problem struct {
+		Type      string        `json:"type"`
+		Title     string        `json:"title"`
+		Code      int           `json:"status"`
+		Message   string        `json:"detail"`
+		RequestID string        `json:"request_id,omitempty"`
+		Errors    []interface{} `json:"errors,omitempty"`
+	}
+
+	problemError struct {
+		Err  string      `json:"error"`
+		Meta interface{} `json:"meta,omitempty"`
+	}
 )
 
 // Create new HttpStatus with the code, message, and default Manage handlers.
@@ -83,7 +108,9 @@ func (h *HttpStatus) after() Manage {
 	return func(c context.Context) {
 		curr := currentCtx(c)
 		if !curr.RW.Written() {
-			if curr.engine.HTMLStatus {
+			if curr.jsonStatus() {
+				curr.problem(h.Code, h.Message, problemErrors(curr))
+			} else if curr.engine.HTMLStatus {
 				curr.RW.Header().Set("Content-Type", "text/html")
 				curr.RW.Write(h.format())
 			} else {
@@ -97,6 +124,58 @@ func (h *HttpStatus) format() []byte {
 	return []byte(fmt.Sprintf(statusHtml, h.Code, h.name(), h.name(), h.Message))
 }
 
+// jsonStatus reports whether a status is served as a json Problem, when the
+// request Accept header prefers json to html, or engine.JSONStatus is true and
+// the Accept header has no preference.
+func (c *Ctx) jsonStatus() bool {
+	accept := c.request.Header.Get("Accept")
+	if accept == "" {
+		return c.engine.JSONStatus
+	}
+	ranges := parseAccept(accept)
+	j := quality(ranges, "application/json")
+	if q := quality(ranges, "application/problem+json"); q > j {
+		j = q
+	}
+	h := quality(ranges, "text/html")
+	if j == h {
+		return c.engine.JSONStatus
+	}
+	return j > h
+}
+
+func (c *Ctx) problem(code int, message string, errors []interface{}) {
+	c.RW.Header().Set("Content-Type", "application/problem+json")
+	json.NewEncoder(c.RW).Encode(Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(code),
+		Code:      code,
+		Message:   message,
+		RequestID: c.RequestID(),
+		Errors:    errors,
+	})
+}
+
+// problemErrors returns the Ctx errors for a Problem, leaving out panics
+// unless engine.ServePanic is true, and any meta that cannot be json encoded.
+func problemErrors(c *Ctx) []interface{} {
+	var errors []interface{}
+	for _, e := range c.Errors {
+		if e.Type&ErrorTypePanic > 0 && !c.engine.ServePanic {
+			continue
+		}
+		pe := problemError{Err: e.Err, Meta: e.Meta}
+		if b, ok := e.Meta.([]byte); ok {
+			pe.Meta = string(b)
+		}
+		if _, err := json.Marshal(pe.Meta); err != nil {
+			pe.Meta = nil
+		}
+		errors = append(errors, pe)
+	}
+	return errors
+}
+
 // Adds any number of custom Manage to the HttpStatus, between the
 // default status before & after manage.
 func (h *HttpStatus) Update(handlers ...Manage) {
@@ -114,10 +193,12 @@ func (h *HttpStatus) Update(handlers ...Manage) {
 func defaultHttpStatuses() HttpStatuses {
 	hss := make(HttpStatuses)
 	hss.New(NewHttpStatus(400, "The browser (or proxy) sent a request that this server could not understand."))
+	hss[400].Update(ValidationHandle)
 	hss.New(NewHttpStatus(401, "The server could not verify that you are authorized to access the URL requested.\nYou either supplied the wrong credentials (e.g. a bad password), or your browser doesn't understand how to supply the credentials required."))
 	hss.New(NewHttpStatus(403, "You do not have the permission to access the requested resource.\nIt is either read-protected or not readable by the server."))
 	hss.New(NewHttpStatus(404, "The requested URL was not found on the server. If you entered the URL manually please check your spelling and try again."))
 	hss.New(NewHttpStatus(405, "The method is not allowed for the requested URL."))
+	hss.New(NewHttpStatus(406, "The resource identified by the request is only capable of generating responses with content characteristics not acceptable according to the accept headers sent in the request."))
 	hss.New(NewHttpStatus(418, "I'M A TEAPOT, NOT A COFFEE MACHINE."))
 	hss.New(NewHttpStatus(500, "The server encountered an internal error and was unable to complete your request. Either the server is overloaded or there is an error in the application."))
 	hss[500].Update(PanicHandle)
@@ -128,17 +209,49 @@ func defaultHttpStatuses() HttpStatuses {
 	return hss
 }
 
+// ValidationHandle is the default Manage for 400. Retrieves all
+// ErrorTypeValidation from context.Context.Errors, and serves the field errors as
+// a html list if engine.HTMLStatus is true and json is not preferred, or
+// otherwise as a json Problem.
+func ValidationHandle(c context.Context) {
+	curr := currentCtx(c)
+	invalid := curr.Errors.ByType(ErrorTypeValidation)
+	if len(invalid) == 0 || curr.RW.Written() {
+		return
+	}
+	message := http.StatusText(400)
+	if s, ok := curr.group.status(400); ok {
+		message = s.Message
+	}
+	if curr.engine.HTMLStatus && !curr.jsonStatus() {
+		var buffer bytes.Buffer
+		for _, i := range invalid {
+			buffer.WriteString(fmt.Sprintf("<li>%s</li>\n", html.EscapeString(i.Err)))
+		}
+		curr.RW.Header().Set("Content-Type", "text/html")
+		curr.RW.Write([]byte(fmt.Sprintf(validationHtml, 400, http.StatusText(400), http.StatusText(400), message, buffer.String())))
+	} else {
+		fields := make([]interface{}, len(invalid))
+		for i, e := range invalid {
+			fields[i] = e.Meta
+		}
+		curr.problem(400, message, fields)
+	}
+}
+
 // PanicHandle is the default Manage for 500 & internal panics. Retrieves all
 // ErrorTypePanic from context.Context.Errors, sends signal, logs to stdout or logger, and
-// serves a basic html page if engine.ServePanic is true.
+// serves a basic html page if engine.ServePanic is true. When json is preferred,
+// the panics are instead served in the json Problem of the 500 HttpStatus.
 func PanicHandle(c context.Context) {
 	curr := currentCtx(c)
 	panics := curr.Errors.ByType(ErrorTypePanic)
+	servePanic := curr.engine.ServePanic && !curr.jsonStatus()
 	var auffer bytes.Buffer
 	for _, p := range panics {
-		sig := fmt.Sprintf("encountered an internal error: %s\n-----\n%s\n-----\n", p.Err, p.Meta)
+		sig := fmt.Sprintf("encountered an internal error in request %s: %s\n-----\n%s\n-----\n", p.RequestID, p.Err, p.Meta)
 		curr.engine.Send("panic", sig)
-		if curr.engine.ServePanic {
+		if servePanic {
 			reader := bufio.NewReader(bytes.NewReader([]byte(fmt.Sprintf("%s", p.Meta))))
 			var err error
 			lineno := 0
@@ -159,10 +272,9 @@ func PanicHandle(c context.Context) {
 			auffer.WriteString(pb)
 		}
 	}
-	if curr.engine.ServePanic {
-		servePanic := fmt.Sprintf(panicHtml, auffer.String())
+	if servePanic {
 		curr.RW.Header().Set("Content-Type", "text/html")
-		curr.RW.Write([]byte(servePanic))
+		curr.RW.Write([]byte(fmt.Sprintf(panicHtml, auffer.String())))
 	}
 }
 
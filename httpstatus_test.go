@@ -1,8 +1,11 @@
 package engine
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"golang.org/x/net/context"
@@ -47,3 +50,103 @@ func TestCustomException(t *testing.T) {
 	testCustomException(418, t)
 	testCustomException(500, t)
 }
+
+func TestInheritedException(t *testing.T) {
+	e, _ := New()
+	api := e.New("/api")
+	v1 := api.New("/v1")
+	v2 := api.New("/v2")
+
+	api.TakeStatus(404, func(c context.Context) { currentCtx(c).RW.Write([]byte("API 404")) })
+	v2.TakeStatus(404, func(c context.Context) { currentCtx(c).RW.Write([]byte("V2 404")) })
+
+	v1.Take("/test", "GET", func(c context.Context) { currentCtx(c).Status(404) })
+	v2.Take("/test", "GET", func(c context.Context) { currentCtx(c).Status(404) })
+
+	expect := func(path, body string) {
+		w := PerformRequest(e, "GET", path)
+		if w.Code != 404 {
+			t.Errorf("%s status code should be 404, was %d", path, w.Code)
+		}
+		if w.Body.String() != body {
+			t.Errorf("%s body should be '%s', but was '%s'.", path, body, w.Body.String())
+		}
+	}
+
+	expect("/api/v1/test", "API 404")
+	expect("/api/v2/test", "V2 404")
+	expect("/api/v1/missing", "API 404")
+	expect("/api/v2/missing", "V2 404")
+	expect("/missing", "")
+}
+
+func performAccept(e *Engine, path, accept string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestJSONStatus(t *testing.T) {
+	expect := func(e *Engine, accept string, json bool) {
+		w := performAccept(e, "/missing", accept)
+		if w.Code != 404 {
+			t.Errorf("Status code should be 404, was %d", w.Code)
+		}
+		isjson := w.Header().Get("Content-Type") == "application/problem+json"
+		if isjson != json {
+			t.Errorf("Accept '%s' served json %t, expected %t: '%s'", accept, isjson, json, w.Body.String())
+		}
+	}
+
+	e, _ := New(HTMLStatus(true))
+	expect(e, "", false)
+	expect(e, "*/*", false)
+	expect(e, "text/html,application/xhtml+xml,*/*;q=0.8", false)
+	expect(e, "application/json", true)
+	expect(e, "application/problem+json, text/html;q=0.5", true)
+
+	e, _ = New(JSONStatus(true))
+	expect(e, "", true)
+	expect(e, "*/*", true)
+	expect(e, "text/html", false)
+}
+
+func TestJSONProblem(t *testing.T) {
+	e, _ := New(JSONStatus(true))
+	e.Take("/error", "GET", func(c context.Context) {
+		curr := currentCtx(c)
+		curr.Error(newError("bad input"), "meta")
+		curr.Status(400)
+	})
+	e.Take("/panic", "GET", func(c context.Context) { panic("problem panic") })
+
+	var p Problem
+	w := PerformRequest(e, "GET", "/error")
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatalf("Body should be json, was '%s': %s", w.Body.String(), err)
+	}
+	if p.Code != 400 || p.Title != http.StatusText(400) || p.Message != e.HttpStatuses[400].Message {
+		t.Errorf("Problem should describe the 400 status, was %+v", p)
+	}
+	if len(p.Errors) != 1 || !strings.Contains(w.Body.String(), `"error":"bad input","meta":"meta"`) {
+		t.Errorf("Problem should hold the Ctx errors, was '%s'", w.Body.String())
+	}
+
+	w = PerformRequest(e, "GET", "/panic")
+	if w.Code != 500 || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("Panic should serve a 500 json Problem, was %d '%s'", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "problem panic") {
+		t.Errorf("Problem should hold the panic with ServePanic, was '%s'", w.Body.String())
+	}
+
+	e.SetConf(ServePanic(false))
+	w = PerformRequest(e, "GET", "/panic")
+	if strings.Contains(w.Body.String(), "problem panic") {
+		t.Errorf("Problem should not hold the panic without ServePanic, was '%s'", w.Body.String())
+	}
+}
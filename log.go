@@ -0,0 +1,240 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// LogEntry holds the fields logged for a served request.
+	LogEntry struct {
+		Time      time.Time
+		Method    string
+		Path      string
+		Route     string
+		Proto     string
+		Status    int
+		Latency   time.Duration
+		Requester string
+		Size      int
+		Referer   string
+		UserAgent string
+		RequestID string
+		Errors    []string
+	}
+
+	// Formatter formats a LogEntry as a single line, using ANSI color when
+	// color is true and the format supports it.
+	Formatter interface {
+		Format(entry *LogEntry, color bool) []byte
+	}
+
+	// RequestLogger logs the LogEntry of every request served with LoggingOn.
+	RequestLogger interface {
+		Log(entry *LogEntry)
+	}
+
+	// TextFormatter formats a LogEntry as an aligned, human readable line.
+	TextFormatter struct{}
+
+	// JSONFormatter formats a LogEntry as a json object.
+	JSONFormatter struct{}
+
+	// LogfmtFormatter formats a LogEntry as logfmt key=value pairs.
+	LogfmtFormatter struct{}
+
+	// CombinedFormatter formats a LogEntry in the Apache combined log format.
+	CombinedFormatter struct{}
+
+	writerLogger struct {
+		mu        sync.Mutex
+		out       io.Writer
+		formatter Formatter
+		color     bool
+	}
+)
+
+// NewRequestLogger returns a RequestLogger writing every LogEntry to out with
+// the Formatter. Color is used only when out is a terminal.
+func NewRequestLogger(out io.Writer, f Formatter) RequestLogger {
+	return &writerLogger{out: out, formatter: f, color: isTerminal(out)}
+}
+
+func (l *writerLogger) Log(entry *LogEntry) {
+	line := l.formatter.Format(entry, l.color)
+	l.mu.Lock()
+	l.out.Write(append(line, '\n'))
+	l.mu.Unlock()
+}
+
+// isTerminal reports whether w is a character device, such as a terminal,
+// and color is not disabled with the NO_COLOR or TERM=dumb environment.
+func isTerminal(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (c *Ctx) logEntry() *LogEntry {
+	entry := &LogEntry{
+		Time:      c.start,
+		Method:    c.method,
+		Path:      c.path,
+		Route:     c.route,
+		Proto:     c.request.Proto,
+		Status:    c.status,
+		Latency:   c.latency,
+		Requester: c.requester,
+		Referer:   c.request.Referer(),
+		UserAgent: c.request.UserAgent(),
+		RequestID: c.RequestID(),
+	}
+	if size := c.RW.Size(); size > 0 {
+		entry.Size = size
+	}
+	for _, e := range c.Errors {
+		entry.Errors = append(entry.Errors, e.Err)
+	}
+	return entry
+}
+
+// logRequest logs the request with the engine RequestLog, or otherwise as
+// text to the "message" queue.
+func (engine *Engine) logRequest(entry *LogEntry) {
+	if engine.RequestLog != nil {
+		engine.RequestLog.Log(entry)
+		return
+	}
+	color := engine.Logger != nil && isTerminal(engine.Logger.Writer())
+	engine.Send("message", string(TextFormatter{}.Format(entry, color)))
+}
+
+func (TextFormatter) Format(entry *LogEntry, color bool) []byte {
+	statusColor, methodColor, resetColor := "", "", ""
+	if color {
+		statusColor, methodColor, resetColor = StatusColor(entry.Status), MethodColor(entry.Method), reset
+	}
+	line := fmt.Sprintf("%v |%s %3d %s| %12v | %s |%s %s %-7s %s",
+		entry.Time.Add(entry.Latency).Format("2006/01/02 - 15:04:05"),
+		statusColor, entry.Status, resetColor,
+		entry.Latency,
+		entry.Requester,
+		methodColor, resetColor, entry.Method,
+		entry.Path)
+	if entry.RequestID != "" {
+		line = fmt.Sprintf("%s | %s", line, entry.RequestID)
+	}
+	return []byte(line)
+}
+
+func (JSONFormatter) Format(entry *LogEntry, color bool) []byte {
+	b, _ := json.Marshal(struct {
+		Time      string   `json:"time"`
+		Method    string   `json:"method"`
+		Path      string   `json:"path"`
+		Route     string   `json:"route,omitempty"`
+		Proto     string   `json:"proto"`
+		Status    int      `json:"status"`
+		Latency   float64  `json:"latency_ms"`
+		Requester string   `json:"requester"`
+		Size      int      `json:"bytes"`
+		Referer   string   `json:"referer,omitempty"`
+		UserAgent string   `json:"user_agent,omitempty"`
+		RequestID string   `json:"request_id,omitempty"`
+		Errors    []string `json:"errors,omitempty"`
+	}{
+		entry.Time.Format(time.RFC3339Nano),
+		entry.Method,
+		entry.Path,
+		entry.Route,
+		entry.Proto,
+		entry.Status,
+		float64(entry.Latency) / float64(time.Millisecond),
+		entry.Requester,
+		entry.Size,
+		entry.Referer,
+		entry.UserAgent,
+		entry.RequestID,
+		entry.Errors,
+	})
+	return b
+}
+
+func (LogfmtFormatter) Format(entry *LogEntry, color bool) []byte {
+	var buffer bytes.Buffer
+	pair := func(key, value string) {
+		if buffer.Len() > 0 {
+			buffer.WriteByte(' ')
+		}
+		buffer.WriteString(key)
+		buffer.WriteByte('=')
+		buffer.WriteString(logfmtValue(value))
+	}
+	pair("time", entry.Time.Format(time.RFC3339Nano))
+	pair("method", entry.Method)
+	pair("path", entry.Path)
+	pair("route", entry.Route)
+	if color {
+		pair("status", StatusColor(entry.Status)+strconv.Itoa(entry.Status)+reset)
+	} else {
+		pair("status", strconv.Itoa(entry.Status))
+	}
+	pair("latency", entry.Latency.String())
+	pair("requester", entry.Requester)
+	pair("bytes", strconv.Itoa(entry.Size))
+	if entry.RequestID != "" {
+		pair("request_id", entry.RequestID)
+	}
+	if len(entry.Errors) > 0 {
+		pair("errors", strings.Join(entry.Errors, "; "))
+	}
+	return buffer.Bytes()
+}
+
+// logfmtValue quotes values that are empty or hold spaces, quotes or '='.
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\"=\\") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+func (CombinedFormatter) Format(entry *LogEntry, color bool) []byte {
+	host := entry.Requester
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	size := "-"
+	if entry.Size > 0 {
+		size = strconv.Itoa(entry.Size)
+	}
+	return []byte(fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %s %s %s",
+		combinedValue(host),
+		entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		entry.Method, entry.Path, entry.Proto,
+		entry.Status,
+		size,
+		strconv.Quote(combinedValue(entry.Referer)),
+		strconv.Quote(combinedValue(entry.UserAgent))))
+}
+
+func combinedValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+type testLogger struct {
+	sync.Mutex
+	entries []*LogEntry
+}
+
+func (l *testLogger) Log(entry *LogEntry) {
+	l.Lock()
+	l.entries = append(l.entries, entry)
+	l.Unlock()
+}
+
+func TestRequestLog(t *testing.T) {
+	l := &testLogger{}
+	e, _ := New(RequestLog(l))
+	e.Take("/user/:name", "GET", func(c context.Context) {
+		curr := currentCtx(c)
+		curr.Error(newError("logged error"), nil)
+		curr.RW.WriteHeader(201)
+		curr.RW.Write([]byte("gopher"))
+	})
+
+	PerformRequest(e, "GET", "/user/gopher")
+	PerformRequest(e, "GET", "/missing")
+
+	if len(l.entries) != 2 {
+		t.Fatalf("RequestLog should log 2 entries, logged %d", len(l.entries))
+	}
+	entry := l.entries[0]
+	if entry.Method != "GET" || entry.Path != "/user/gopher" || entry.Route != "/user/:name" ||
+		entry.Status != 201 || entry.Size != 6 || len(entry.Errors) != 1 || entry.Errors[0] != "logged error" {
+		t.Errorf("Logged entry was %+v", entry)
+	}
+	if entry := l.entries[1]; entry.Route != "" || entry.Status != 404 {
+		t.Errorf("Logged entry for a missing route was %+v", entry)
+	}
+}
+
+func TestFormatters(t *testing.T) {
+	entry := &LogEntry{
+		Time:      time.Date(2015, 6, 1, 12, 30, 0, 0, time.UTC),
+		Method:    "GET",
+		Path:      "/user/gopher",
+		Route:     "/user/:name",
+		Proto:     "HTTP/1.1",
+		Status:    200,
+		Latency:   1500 * time.Microsecond,
+		Requester: "127.0.0.1:5000",
+		Size:      6,
+		UserAgent: "test agent",
+		Errors:    []string{"an error"},
+	}
+
+	var j map[string]interface{}
+	if err := json.Unmarshal(JSONFormatter{}.Format(entry, true), &j); err != nil {
+		t.Fatalf("JSONFormatter should format json: %s", err)
+	}
+	if j["route"] != "/user/:name" || j["status"] != float64(200) || j["latency_ms"] != 1.5 || j["bytes"] != float64(6) {
+		t.Errorf("JSONFormatter formatted %v", j)
+	}
+
+	logfmt := string(LogfmtFormatter{}.Format(entry, false))
+	expected := `time=2015-06-01T12:30:00Z method=GET path=/user/gopher route=/user/:name status=200 latency=1.5ms requester=127.0.0.1:5000 bytes=6 errors="an error"`
+	if logfmt != expected {
+		t.Errorf("LogfmtFormatter formatted\n%s\nexpected\n%s", logfmt, expected)
+	}
+	if colored := string(LogfmtFormatter{}.Format(entry, true)); !strings.Contains(colored, StatusColor(200)) {
+		t.Errorf("LogfmtFormatter with color should color the status, was %s", colored)
+	}
+
+	combined := string(CombinedFormatter{}.Format(entry, false))
+	expected = `127.0.0.1 - - [01/Jun/2015:12:30:00 +0000] "GET /user/gopher HTTP/1.1" 200 6 "-" "test agent"`
+	if combined != expected {
+		t.Errorf("CombinedFormatter formatted\n%s\nexpected\n%s", combined, expected)
+	}
+
+	if text := string(TextFormatter{}.Format(entry, false)); strings.Contains(text, "\x1b") {
+		t.Errorf("TextFormatter without color should not hold ANSI codes, was %q", text)
+	}
+}
+
+func TestNewRequestLogger(t *testing.T) {
+	var buffer bytes.Buffer
+	l := NewRequestLogger(&buffer, LogfmtFormatter{})
+	l.Log(&LogEntry{Method: "GET", Status: 500})
+	if out := buffer.String(); !strings.Contains(out, "status=500 ") || strings.Contains(out, "\x1b") || !strings.HasSuffix(out, "\n") {
+		t.Errorf("RequestLogger should write uncolored lines to a buffer, wrote %q", out)
+	}
+
+	f, err := ioutil.TempFile("", "engine-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if isTerminal(f) {
+		t.Errorf("A regular file should not be a terminal")
+	}
+}
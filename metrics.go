@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultBuckets are the latency histogram buckets, in seconds, of NewMetrics
+// without any buckets.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type (
+	// Metrics collects request counters and latency histograms, labelled by
+	// route pattern, method and status class, and serves them in the
+	// Prometheus text format.
+	Metrics struct {
+		mu      sync.Mutex
+		buckets []float64
+		series  map[metricLabels]*metricSeries
+	}
+
+	metricLabels struct {
+		route  string
+		method string
+		status string
+	}
+
+	metricSeries struct {
+		count   uint64
+		sum     float64
+		buckets []uint64
+	}
+)
+
+// NewMetrics returns Metrics with latency histogram buckets in seconds, or the
+// DefaultBuckets without any.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return &Metrics{buckets: b, series: make(map[metricLabels]*metricSeries)}
+}
+
+// standardMethods are the methods labelled by name without a matched route.
+var standardMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true,
+	"DELETE": true, "CONNECT": true, "OPTIONS": true, "TRACE": true,
+}
+
+// Observe counts the request of the LogEntry. Requests matching no route are
+// labelled with the route "unmatched", and a method other than the standard
+// methods with "other", so client input never becomes an unbounded label.
+func (m *Metrics) Observe(entry *LogEntry) {
+	labels := metricLabels{route: entry.Route, method: entry.Method, status: statusClass(entry.Status)}
+	if labels.route == "" {
+		labels.route = "unmatched"
+		if !standardMethods[labels.method] {
+			labels.method = "other"
+		}
+	}
+	seconds := float64(entry.Latency) / float64(time.Second)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.series[labels]
+	if !ok {
+		s = &metricSeries{buckets: make([]uint64, len(m.buckets))}
+		m.series[labels] = s
+	}
+	s.count++
+	s.sum += seconds
+	for i, le := range m.buckets {
+		if seconds <= le {
+			s.buckets[i]++
+		}
+	}
+}
+
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%dxx", code/100)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	labels := make([]metricLabels, 0, len(m.series))
+	for l := range m.series {
+		labels = append(labels, l)
+	}
+	sort.Sort(byLabels(labels))
+
+	var buffer bytes.Buffer
+	buffer.WriteString("# HELP engine_requests_total Total requests served.\n")
+	buffer.WriteString("# TYPE engine_requests_total counter\n")
+	for _, l := range labels {
+		fmt.Fprintf(&buffer, "engine_requests_total{%s} %d\n", l, m.series[l].count)
+	}
+	buffer.WriteString("# HELP engine_request_duration_seconds Request latency in seconds.\n")
+	buffer.WriteString("# TYPE engine_request_duration_seconds histogram\n")
+	for _, l := range labels {
+		s := m.series[l]
+		for i, le := range m.buckets {
+			fmt.Fprintf(&buffer, "engine_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", l, formatFloat(le), s.buckets[i])
+		}
+		fmt.Fprintf(&buffer, "engine_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", l, s.count)
+		fmt.Fprintf(&buffer, "engine_request_duration_seconds_sum{%s} %s\n", l, formatFloat(s.sum))
+		fmt.Fprintf(&buffer, "engine_request_duration_seconds_count{%s} %d\n", l, s.count)
+	}
+	m.mu.Unlock()
+	return buffer.WriteTo(w)
+}
+
+// Manage serves the metrics in the Prometheus text format, mounted on any
+// route, e.g. engine.Take("/metrics", "GET", metrics.Manage).
+func (m *Metrics) Manage(c context.Context) {
+	var buffer bytes.Buffer
+	m.WriteTo(&buffer)
+	currentCtx(c).Bytes(200, "text/plain; version=0.0.4; charset=utf-8", buffer.Bytes())
+}
+
+func (l metricLabels) String() string {
+	return fmt.Sprintf("route=\"%s\",method=\"%s\",status=\"%s\"", escapeLabel(l.route), escapeLabel(l.method), l.status)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type byLabels []metricLabels
+
+func (a byLabels) Len() int      { return len(a) }
+func (a byLabels) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byLabels) Less(i, j int) bool {
+	if a[i].route != a[j].route {
+		return a[i].route < a[j].route
+	}
+	if a[i].method != a[j].method {
+		return a[i].method < a[j].method
+	}
+	return a[i].status < a[j].status
+}
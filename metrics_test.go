@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics(0.1, 1)
+	e, _ := New(CollectMetrics(m))
+	e.Take("/user/:name", "GET", func(c context.Context) {
+		currentCtx(c).RW.WriteHeader(201)
+	})
+	e.Take("/metrics", "GET", m.Manage)
+
+	PerformRequest(e, "GET", "/user/gopher")
+	PerformRequest(e, "GET", "/user/badger")
+	PerformRequest(e, "GET", "/missing")
+	PerformRequest(e, "FOO", "/missing")
+	PerformRequest(e, "BAR1", "/missing")
+
+	w := PerformRequest(e, "GET", "/metrics")
+	if w.Code != 200 || !strings.HasPrefix(w.HeaderMap.Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("Metrics served %d with Content-Type %q", w.Code, w.HeaderMap.Get("Content-Type"))
+	}
+	body := w.Body.String()
+	for _, line := range []string{
+		"# TYPE engine_requests_total counter",
+		`engine_requests_total{route="/user/:name",method="GET",status="2xx"} 2`,
+		`engine_requests_total{route="unmatched",method="GET",status="4xx"} 1`,
+		`engine_requests_total{route="unmatched",method="other",status="4xx"} 2`,
+		"# TYPE engine_request_duration_seconds histogram",
+		`engine_request_duration_seconds_bucket{route="/user/:name",method="GET",status="2xx",le="0.1"} 2`,
+		`engine_request_duration_seconds_bucket{route="/user/:name",method="GET",status="2xx",le="+Inf"} 2`,
+		`engine_request_duration_seconds_count{route="/user/:name",method="GET",status="2xx"} 2`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Metrics should contain %q, but were\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "gopher") || strings.Contains(body, "/missing") || strings.Contains(body, "FOO") {
+		t.Errorf("Metrics should be labelled by route pattern, but were\n%s", body)
+	}
+}
+
+func TestMetricsObserve(t *testing.T) {
+	m := NewMetrics(1, 0.5)
+	m.Observe(&LogEntry{Route: "/", Method: "POST", Status: 503, Latency: 750 * time.Millisecond})
+	m.Observe(&LogEntry{Route: `/"q"`, Method: "GET", Status: 999, Latency: 2 * time.Second})
+
+	var b bytes.Buffer
+	m.WriteTo(&b)
+	for _, line := range []string{
+		`engine_request_duration_seconds_bucket{route="/",method="POST",status="5xx",le="0.5"} 0`,
+		`engine_request_duration_seconds_bucket{route="/",method="POST",status="5xx",le="1"} 1`,
+		`engine_request_duration_seconds_sum{route="/",method="POST",status="5xx"} 0.75`,
+		`engine_requests_total{route="/\"q\"",method="GET",status="unknown"} 1`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("Metrics should contain %q, but were\n%s", line, b.String())
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+type (
+	// UUID is a 16 byte universally unique identifier, as returned by
+	// Params.UUID.
+	UUID [16]byte
+)
+
+var uuidType = reflect.TypeOf(UUID{})
+
+// ParseUUID parses a UUID in the canonical 8-4-4-4-12 hexadecimal form.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, newError("'%s' is not a valid uuid", s)
+	}
+	b := []byte(s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:])
+	if _, err := hex.Decode(u[:], b); err != nil {
+		return u, newError("'%s' is not a valid uuid", s)
+	}
+	return u, nil
+}
+
+func (u UUID) String() string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+func (ps Params) value(name string) (string, error) {
+	for i := range ps {
+		if ps[i].Key == name {
+			return ps[i].Value, nil
+		}
+	}
+	return "", newError("no param named %s", name)
+}
+
+func (ps Params) convert(name string, as interface{}) error {
+	v, err := ps.value(name)
+	if err != nil {
+		return err
+	}
+	return setParam(name, reflect.ValueOf(as).Elem(), v)
+}
+
+// Int returns the value of the named Param as an int.
+func (ps Params) Int(name string) (i int, err error) {
+	err = ps.convert(name, &i)
+	return i, err
+}
+
+// Int64 returns the value of the named Param as an int64.
+func (ps Params) Int64(name string) (i int64, err error) {
+	err = ps.convert(name, &i)
+	return i, err
+}
+
+// Uint returns the value of the named Param as a uint.
+func (ps Params) Uint(name string) (u uint, err error) {
+	err = ps.convert(name, &u)
+	return u, err
+}
+
+// Bool returns the value of the named Param as a bool, accepting the values
+// accepted by strconv.ParseBool.
+func (ps Params) Bool(name string) (b bool, err error) {
+	err = ps.convert(name, &b)
+	return b, err
+}
+
+// Float64 returns the value of the named Param as a float64.
+func (ps Params) Float64(name string) (f float64, err error) {
+	err = ps.convert(name, &f)
+	return f, err
+}
+
+// UUID returns the value of the named Param as a UUID.
+func (ps Params) UUID(name string) (u UUID, err error) {
+	err = ps.convert(name, &u)
+	return u, err
+}
+
+// Bind fills the fields of the struct pointed to by v from the Params, keyed
+// by the `param` tag of each field, e.g. `param:"id"`. Untagged fields and
+// fields without a matching Param are left unchanged.
+func (ps Params) Bind(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return newError("Params can only bind to a struct pointer, not %T", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		name := f.Tag.Get("param")
+		if name == "" || name == "-" || f.PkgPath != "" {
+			continue
+		}
+		value, err := ps.value(name)
+		if err != nil {
+			continue
+		}
+		if err := setParam(name, rv.Field(i), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setParam(name string, field reflect.Value, value string) error {
+	if err := setField(field, value); err != nil {
+		return newError("param %s value '%s' is not a valid %s", name, value, field.Type())
+	}
+	return nil
+}
+
+// setField sets a string, bool, numeric or UUID field from the string value.
+func setField(field reflect.Value, value string) error {
+	if field.Type() == uuidType {
+		u, err := ParseUUID(value)
+		if err == nil {
+			field.Set(reflect.ValueOf(u))
+		}
+		return err
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return newError("cannot set a field of type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"net/http"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+var testParams = Params{
+	Param{"int", "-42"},
+	Param{"uint", "42"},
+	Param{"bool", "true"},
+	Param{"float", "4.2"},
+	Param{"uuid", "6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+	Param{"word", "gopher"},
+}
+
+func TestParamsTyped(t *testing.T) {
+	if i, err := testParams.Int("int"); err != nil || i != -42 {
+		t.Errorf("Int should be -42, was %d (%v)", i, err)
+	}
+	if i, err := testParams.Int64("int"); err != nil || i != -42 {
+		t.Errorf("Int64 should be -42, was %d (%v)", i, err)
+	}
+	if u, err := testParams.Uint("uint"); err != nil || u != 42 {
+		t.Errorf("Uint should be 42, was %d (%v)", u, err)
+	}
+	if b, err := testParams.Bool("bool"); err != nil || !b {
+		t.Errorf("Bool should be true, was %t (%v)", b, err)
+	}
+	if f, err := testParams.Float64("float"); err != nil || f != 4.2 {
+		t.Errorf("Float64 should be 4.2, was %f (%v)", f, err)
+	}
+	if u, err := testParams.UUID("uuid"); err != nil || u.String() != "6ba7b810-9dad-11d1-80b4-00c04fd430c8" {
+		t.Errorf("UUID should be 6ba7b810-9dad-11d1-80b4-00c04fd430c8, was %s (%v)", u, err)
+	}
+
+	if _, err := testParams.Int("word"); err == nil {
+		t.Error("Int of a non integer param should return an error")
+	}
+	if _, err := testParams.Uint("int"); err == nil {
+		t.Error("Uint of a negative param should return an error")
+	}
+	if _, err := testParams.UUID("word"); err == nil {
+		t.Error("UUID of a non uuid param should return an error")
+	}
+	if _, err := testParams.Int("missing"); err == nil {
+		t.Error("Int of a missing param should return an error")
+	}
+}
+
+type boundParams struct {
+	Int     int     `param:"int"`
+	Uint    uint8   `param:"uint"`
+	Bool    bool    `param:"bool"`
+	Float   float32 `param:"float"`
+	UUID    UUID    `param:"uuid"`
+	Word    string  `param:"word"`
+	Missing string  `param:"missing"`
+	Skipped string
+}
+
+func TestParamsBind(t *testing.T) {
+	var b boundParams
+	if err := testParams.Bind(&b); err != nil {
+		t.Fatalf("Bind returned error: %s", err)
+	}
+	if b.Int != -42 || b.Uint != 42 || !b.Bool || b.Float != 4.2 || b.Word != "gopher" ||
+		b.UUID.String() != "6ba7b810-9dad-11d1-80b4-00c04fd430c8" || b.Missing != "" || b.Skipped != "" {
+		t.Errorf("Bind filled wrong values: %+v", b)
+	}
+
+	if err := testParams.Bind(b); err == nil {
+		t.Error("Bind to a non pointer should return an error")
+	}
+
+	var bad struct {
+		Word int `param:"word"`
+	}
+	if err := testParams.Bind(&bad); err == nil {
+		t.Error("Bind of a non integer param to an int should return an error")
+	}
+}
+
+func TestCtxBindParams(t *testing.T) {
+	var errs int
+	e, _ := New()
+	e.Take("/user/:id", "GET", func(c context.Context) {
+		curr := currentCtx(c)
+		var u struct {
+			ID int `param:"id"`
+		}
+		if err := curr.BindParams(&u); err != nil {
+			errs = len(curr.Errors.ByType(ErrorTypeExternal))
+			curr.Status(400)
+		}
+	})
+
+	w := PerformRequest(e, "GET", "/user/gopher")
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Status code should be %v, was %d", http.StatusBadRequest, w.Code)
+	}
+	if errs != 1 {
+		t.Errorf("Ctx should have 1 external error, had %d", errs)
+	}
+}
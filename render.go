@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Offer is a content type, and the data to render for the content type,
+	// offered to Ctx.Negotiate.
+	Offer struct {
+		ContentType string
+		Data        interface{}
+	}
+
+	acceptRange struct {
+		mediaType string
+		q         float64
+	}
+)
+
+// Bytes writes the code, Content-Type and data to the response.
+func (c *Ctx) Bytes(code int, contentType string, data []byte) error {
+	c.RW.Header().Set("Content-Type", contentType)
+	c.RW.WriteHeader(code)
+	_, err := c.RW.Write(data)
+	return err
+}
+
+// JSON writes the code and data encoded as json to the response. An encoding
+// error is attached to the Ctx, and calls the 500 HttpStatus.
+func (c *Ctx) JSON(code int, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return c.renderError(err, data)
+	}
+	return c.Bytes(code, "application/json; charset=utf-8", b)
+}
+
+// XML writes the code and data encoded as xml to the response. An encoding
+// error is attached to the Ctx, and calls the 500 HttpStatus.
+func (c *Ctx) XML(code int, data interface{}) error {
+	b, err := xml.Marshal(data)
+	if err != nil {
+		return c.renderError(err, data)
+	}
+	return c.Bytes(code, "application/xml; charset=utf-8", b)
+}
+
+// Text writes the code and formatted text to the response.
+func (c *Ctx) Text(code int, format string, values ...interface{}) error {
+	return c.Bytes(code, "text/plain; charset=utf-8", []byte(fmt.Sprintf(format, values...)))
+}
+
+// HTML writes the code and html string to the response.
+func (c *Ctx) HTML(code int, html string) error {
+	return c.Bytes(code, "text/html; charset=utf-8", []byte(html))
+}
+
+func (c *Ctx) renderError(err error, data interface{}) error {
+	c.errorTyped(err, ErrorTypeInternal, data)
+	c.Status(500)
+	return err
+}
+
+// Negotiate renders the data of the offer best matching the request Accept
+// header, preferring earlier offers when equally acceptable, with the code.
+// Data for json or xml content types is encoded, data for other content types
+// is written as a string or []byte. When no offer is acceptable the 406
+// HttpStatus is called.
+func (c *Ctx) Negotiate(code int, offers ...Offer) error {
+	offer, ok := negotiate(c.request.Header.Get("Accept"), offers)
+	if !ok {
+		c.Status(406)
+		return newError("no acceptable offer for '%s'", c.request.Header.Get("Accept"))
+	}
+
+	ct := offer.ContentType
+	switch {
+	case ct == "application/json" || strings.HasSuffix(ct, "+json"):
+		return c.JSON(code, offer.Data)
+	case ct == "application/xml" || ct == "text/xml" || strings.HasSuffix(ct, "+xml"):
+		return c.XML(code, offer.Data)
+	}
+
+	switch d := offer.Data.(type) {
+	case []byte:
+		return c.Bytes(code, ct, d)
+	case string:
+		return c.Bytes(code, ct, []byte(d))
+	}
+	return c.Bytes(code, ct, []byte(fmt.Sprint(offer.Data)))
+}
+
+// negotiate returns the offer with the highest quality match in the Accept
+// header. A missing Accept header accepts the first offer.
+func negotiate(accept string, offers []Offer) (Offer, bool) {
+	if len(offers) == 0 {
+		return Offer{}, false
+	}
+	if accept == "" {
+		return offers[0], true
+	}
+
+	ranges := parseAccept(accept)
+	best, bestq := -1, 0.0
+	for i, o := range offers {
+		if q := quality(ranges, o.ContentType); q > bestq {
+			best, bestq = i, q
+		}
+	}
+	if best < 0 {
+		return Offer{}, false
+	}
+	return offers[best], true
+}
+
+// parseAccept parses the media ranges of an Accept header, most specific first.
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		ar := acceptRange{mediaType: strings.ToLower(strings.TrimSpace(params[0])), q: 1}
+		if ar.mediaType == "" {
+			continue
+		}
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if q, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					ar.q = q
+				}
+			}
+		}
+		ranges = append(ranges, ar)
+	}
+	sort.Stable(bySpecificity(ranges))
+	return ranges
+}
+
+// quality returns the q value of the most specific media range matching the
+// content type.
+func quality(ranges []acceptRange, contentType string) float64 {
+	ct := strings.ToLower(contentType)
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = strings.TrimSpace(ct[:i])
+	}
+	for _, r := range ranges {
+		switch {
+		case r.mediaType == ct,
+			r.mediaType == "*/*",
+			strings.HasSuffix(r.mediaType, "/*") && strings.HasPrefix(ct, r.mediaType[:len(r.mediaType)-1]):
+			return r.q
+		}
+	}
+	return 0
+}
+
+type bySpecificity []acceptRange
+
+func (a bySpecificity) Len() int      { return len(a) }
+func (a bySpecificity) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a bySpecificity) Less(i, j int) bool {
+	return specificity(a[i].mediaType) > specificity(a[j].mediaType)
+}
+
+func specificity(mediaType string) int {
+	switch {
+	case mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(mediaType, "/*"):
+		return 1
+	}
+	return 2
+}
@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+type rendered struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func testRender(render func(*Ctx), contentType string, body string, t *testing.T) {
+	e, _ := New()
+	e.Take("/render", "GET", func(c context.Context) { render(currentCtx(c)) })
+
+	w := PerformRequest(e, "GET", "/render")
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Status code should be %v, was %d", http.StatusCreated, w.Code)
+	}
+	if ct := w.HeaderMap.Get("Content-Type"); ct != contentType {
+		t.Errorf("Content-Type should be %s, was %s", contentType, ct)
+	}
+	if w.Body.String() != body {
+		t.Errorf("Body should be '%s', was '%s'", body, w.Body.String())
+	}
+}
+
+func TestRender(t *testing.T) {
+	testRender(func(c *Ctx) { c.JSON(201, rendered{"gopher"}) }, "application/json; charset=utf-8", `{"name":"gopher"}`, t)
+	testRender(func(c *Ctx) { c.XML(201, rendered{"gopher"}) }, "application/xml; charset=utf-8", `<rendered><name>gopher</name></rendered>`, t)
+	testRender(func(c *Ctx) { c.Text(201, "hello %s", "gopher") }, "text/plain; charset=utf-8", "hello gopher", t)
+	testRender(func(c *Ctx) { c.HTML(201, "<p>gopher</p>") }, "text/html; charset=utf-8", "<p>gopher</p>", t)
+	testRender(func(c *Ctx) { c.Bytes(201, "image/png", []byte("PNG")) }, "image/png", "PNG", t)
+}
+
+func performNegotiate(accept string) *httptest.ResponseRecorder {
+	e, _ := New()
+	e.Take("/negotiate", "GET", func(c context.Context) {
+		currentCtx(c).Negotiate(200,
+			Offer{"application/json", rendered{"gopher"}},
+			Offer{"application/xml", rendered{"gopher"}},
+			Offer{"text/html", "<p>gopher</p>"},
+		)
+	})
+	req, _ := http.NewRequest("GET", "/negotiate", nil)
+	req.Header.Set("Accept", accept)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestNegotiate(t *testing.T) {
+	negotiated := []struct {
+		accept      string
+		code        int
+		contentType string
+	}{
+		{"", 200, "application/json; charset=utf-8"},
+		{"*/*", 200, "application/json; charset=utf-8"},
+		{"application/xml", 200, "application/xml; charset=utf-8"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", 200, "text/html"},
+		{"application/json;q=0.5, application/xml", 200, "application/xml; charset=utf-8"},
+		{"text/*", 200, "text/html"},
+		{"image/png", 406, ""},
+		{"application/json;q=0", 406, ""},
+	}
+	for _, n := range negotiated {
+		w := performNegotiate(n.accept)
+		if w.Code != n.code {
+			t.Errorf("Accept '%s' status code should be %d, was %d", n.accept, n.code, w.Code)
+		}
+		if n.contentType != "" && w.HeaderMap.Get("Content-Type") != n.contentType {
+			t.Errorf("Accept '%s' Content-Type should be %s, was %s", n.accept, n.contentType, w.HeaderMap.Get("Content-Type"))
+		}
+	}
+}
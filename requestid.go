@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RequestIDHeader is the header an incoming request ID is accepted from, and
+// the ID of every request is echoed in.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestID is the longest incoming request ID accepted.
+const maxRequestID = 128
+
+var (
+	requestPrefix  = newRequestPrefix()
+	requestCounter uint64
+)
+
+// RequestID returns the ID of the request, accepted from the X-Request-ID
+// header or generated when the header is missing or invalid.
+func (c *Ctx) RequestID() string {
+	return c.recorder.id
+}
+
+// requestID returns a valid incoming request ID of the request, or a new one.
+func requestID(req *http.Request) string {
+	if id := req.Header.Get(RequestIDHeader); validRequestID(id) {
+		return id
+	}
+	return fmt.Sprintf("%s-%06d", requestPrefix, atomic.AddUint64(&requestCounter, 1))
+}
+
+// validRequestID reports whether the id is non-empty, not overly long, and
+// printable ASCII without spaces, keeping it safe for headers and log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestPrefix returns a random prefix for the generated request IDs of
+// the process, unique across restarts and instances, or the start time when
+// random bytes are unavailable.
+func newRequestPrefix() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestRequestID(t *testing.T) {
+	l := &testLogger{}
+	e, _ := New(RequestLog(l))
+	var ids []string
+	e.Take("/id", "GET", func(c context.Context) {
+		ids = append(ids, currentCtx(c).RequestID())
+	})
+
+	req, _ := http.NewRequest("GET", "/id", nil)
+	req.Header.Set(RequestIDHeader, "upstream-42")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	if ids[0] != "upstream-42" || w.HeaderMap.Get(RequestIDHeader) != "upstream-42" {
+		t.Errorf("An incoming request ID should be kept and echoed, was %q and %q", ids[0], w.HeaderMap.Get(RequestIDHeader))
+	}
+
+	req.Header.Set(RequestIDHeader, "not valid\n")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	w = PerformRequest(e, "GET", "/id")
+	if ids[1] == "" || ids[1] == "not valid\n" || ids[1] == ids[2] {
+		t.Errorf("Request IDs should be generated and unique, were %q and %q", ids[1], ids[2])
+	}
+	if w.HeaderMap.Get(RequestIDHeader) != ids[2] {
+		t.Errorf("A generated request ID should be echoed, was %q", w.HeaderMap.Get(RequestIDHeader))
+	}
+	for i, entry := range l.entries {
+		if entry.RequestID != ids[i] {
+			t.Errorf("Logged request ID was %q, expected %q", entry.RequestID, ids[i])
+		}
+	}
+}
+
+func TestRequestIDErrors(t *testing.T) {
+	e, _ := New()
+	var sigs []string
+	e.AddQueue("panic", func(sig string) { sigs = append(sigs, sig) }, QueueOptions{})
+	var errs errorMsgs
+	var fmtd string
+	e.Take("/panic", "GET", func(c context.Context) {
+		curr := currentCtx(c)
+		defer func() {
+			errs = curr.Errors
+			fmtd = curr.Fmt()
+		}()
+		curr.Error(newError("an error"), nil)
+		panic("request panic")
+	})
+
+	w := performAccept(e, "/panic", "application/json")
+	id := w.HeaderMap.Get(RequestIDHeader)
+	if id == "" {
+		t.Fatal("A request ID should be echoed")
+	}
+	if len(errs) != 1 || errs[0].RequestID != id {
+		t.Errorf("Ctx.Errors should hold the request ID %q, were %+v", id, errs)
+	}
+	if len(sigs) != 1 || !strings.Contains(sigs[0], id) {
+		t.Errorf("The panic signal should hold the request ID %q, was %q", id, sigs)
+	}
+	if !strings.Contains(w.Body.String(), `"request_id":"`+id+`"`) {
+		t.Errorf("The json Problem should hold the request ID %q, was %s", id, w.Body.String())
+	}
+	if !strings.HasSuffix(fmtd, id) {
+		t.Errorf("The recorder should hold the request ID %q, was %q", id, fmtd)
+	}
+}
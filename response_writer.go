@@ -3,6 +3,7 @@ package engine
 import (
 	"bufio"
 	"errors"
+	"strconv"
 
 	"net"
 	"net/http"
@@ -30,6 +31,14 @@ type (
 		status int
 		size   int
 	}
+
+	// headResponseWriter wraps a ResponseWriter for HEAD requests handled by
+	// a GET Manage, discarding the body while keeping headers, status and
+	// Content-Length.
+	headResponseWriter struct {
+		ResponseWriter
+		size int
+	}
 )
 
 func (w *responseWriter) reset(writer http.ResponseWriter) {
@@ -91,3 +100,43 @@ func (w *responseWriter) Flush() {
 		flusher.Flush()
 	}
 }
+
+func newHeadResponseWriter(rw ResponseWriter) *headResponseWriter {
+	return &headResponseWriter{ResponseWriter: rw, size: NotWritten}
+}
+
+// Write discards data, counting the bytes toward Content-Length.
+func (w *headResponseWriter) Write(data []byte) (int, error) {
+	if w.size == NotWritten {
+		w.size = 0
+	}
+	w.size += len(data)
+	return len(data), nil
+}
+
+// WriteHeaderNow sets a Content-Length from the discarded body, if none was
+// set, and writes the headers to the wrapped ResponseWriter.
+func (w *headResponseWriter) WriteHeaderNow() {
+	if !w.ResponseWriter.Written() {
+		if w.size > 0 && w.Header().Get("Content-Length") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(w.size))
+		}
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *headResponseWriter) Size() int {
+	if w.size != NotWritten {
+		return w.size
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *headResponseWriter) Written() bool {
+	return w.size != NotWritten || w.ResponseWriter.Written()
+}
+
+func (w *headResponseWriter) Flush() {
+	w.WriteHeaderNow()
+	w.ResponseWriter.Flush()
+}
@@ -1,6 +1,9 @@
 package engine
 
 import (
+	"bytes"
+	"net/url"
+	"regexp"
 	"strings"
 	"unicode"
 )
@@ -11,6 +14,18 @@ const (
 	catchAll nodeType = 2
 )
 
+var (
+	// Named param constraints, e.g. ':id{int}'. Any other constraint is used as
+	// a regular expression, e.g. ':slug{[a-z-]+}'.
+	constraints = map[string]string{
+		"int":   `-?[0-9]+`,
+		"uint":  `[0-9]+`,
+		"alpha": `[a-zA-Z]+`,
+		"alnum": `[a-zA-Z0-9]+`,
+		"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+	}
+)
+
 type (
 	// Param is a single URL parameter, consisting of a key and a value.
 	Param struct {
@@ -26,14 +41,16 @@ type (
 	nodeType uint8
 
 	node struct {
-		path      string
-		wildChild bool
-		nType     nodeType
-		maxParams uint8
-		indices   []byte
-		children  []*node
-		manage    Manage
-		priority  uint32
+		path       string
+		wildChild  bool
+		nType      nodeType
+		maxParams  uint8
+		indices    []byte
+		children   []*node
+		manage     Manage
+		priority   uint32
+		key        string
+		constraint *regexp.Regexp
 	}
 )
 
@@ -62,6 +79,10 @@ func countParams(path string) uint8 {
 			continue
 		}
 		n++
+		// skip to the wildcard end, past any constraint
+		for i < len(path) && path[i] != '/' {
+			i++
+		}
 	}
 	if n >= 255 {
 		return 255
@@ -69,6 +90,85 @@ func countParams(path string) uint8 {
 	return uint8(n)
 }
 
+// fillPath fills the wildcards of the path with the values keyed by wildcard
+// name. Param values must be a non-empty path segment; catchAll values may
+// contain '/' and have any leading '/' trimmed.
+func fillPath(path string, values map[string]string) (string, error) {
+	var buf bytes.Buffer
+	for i, max := 0, len(path); i < max; {
+		c := path[i]
+		if c != ':' && c != '*' {
+			buf.WriteByte(c)
+			i++
+			continue
+		}
+
+		// find wildcard end (either '/' or path end)
+		end := i + 1
+		for end < max && path[end] != '/' {
+			end++
+		}
+
+		key, constraint := path[i+1:end], (*regexp.Regexp)(nil)
+		if c == ':' {
+			key, constraint = splitParam(path[i:end])
+		}
+
+		value, ok := values[key]
+		if !ok {
+			return "", newError("missing parameter %s for path %s", key, path)
+		}
+
+		if c == ':' {
+			if value == "" || strings.IndexByte(value, '/') >= 0 {
+				return "", newError("parameter %s value '%s' is not a path segment", key, value)
+			}
+			if constraint != nil && !constraint.MatchString(value) {
+				return "", newError("parameter %s value '%s' does not match %s", key, value, path[i:end])
+			}
+			value = url.PathEscape(value)
+		} else {
+			// escape each segment of a catch-all, keeping the separators
+			segments := strings.Split(strings.TrimPrefix(value, "/"), "/")
+			for j, segment := range segments {
+				segments[j] = url.PathEscape(segment)
+			}
+			value = strings.Join(segments, "/")
+		}
+
+		buf.WriteString(value)
+		i = end
+	}
+	return buf.String(), nil
+}
+
+// splitParam splits a ':name{constraint}' param wildcard into the name and the
+// compiled constraint, or nil for a param without a constraint. Constraints
+// must match the entire param value, and cannot contain '/'.
+func splitParam(wildcard string) (string, *regexp.Regexp) {
+	name := wildcard[1:]
+	b := strings.IndexByte(name, '{')
+	if b < 0 {
+		return name, nil
+	}
+
+	if name[len(name)-1] != '}' {
+		panic("param constraints must be enclosed in braces")
+	}
+
+	expr := name[b+1 : len(name)-1]
+	if named, ok := constraints[expr]; ok {
+		expr = named
+	}
+
+	constraint, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		panic("invalid param constraint: " + err.Error())
+	}
+
+	return name[:b], constraint
+}
+
 // increments priority of the given child and reorders if necessary
 func (n *node) incrementChildPrio(i int) int {
 	n.children[i].priority++
@@ -89,6 +189,16 @@ func (n *node) incrementChildPrio(i int) int {
 	return i
 }
 
+// addChild adds a static child, keeping any wildcard child as the last child.
+func (n *node) addChild(child *node) {
+	if n.wildChild && len(n.children) > 0 {
+		wild := n.children[len(n.children)-1]
+		n.children = append(n.children[:len(n.children)-1], child, wild)
+	} else {
+		n.children = append(n.children, child)
+	}
+}
+
 // addRoute adds a node with the given handle to the path.
 // Not concurrency-safe!
 func (n *node) addRoute(path string, manage Manage) {
@@ -140,8 +250,11 @@ func (n *node) addRoute(path string, manage Manage) {
 			if i < len(path) {
 				path = path[i:]
 
-				if n.wildChild {
-					n = n.children[0]
+				// A wildcard path must match the wildcard child, while a static
+				// path may sit alongside a param child but not a catchAll
+				if n.wildChild && (path[0] == ':' || path[0] == '*' ||
+					n.children[len(n.children)-1].nType == catchAll) {
+					n = n.children[len(n.children)-1]
 					n.priority++
 
 					// Update maxParams of the child node
@@ -185,7 +298,7 @@ func (n *node) addRoute(path string, manage Manage) {
 					child := &node{
 						maxParams: numParams,
 					}
-					n.children = append(n.children, child)
+					n.addChild(child)
 					n.incrementChildPrio(len(n.indices) - 1)
 					n = child
 				}
@@ -215,12 +328,6 @@ func (n *node) insertChild(numParams uint8, path string, manage Manage) {
 			continue
 		}
 
-		// Check if this Node existing children which would be
-		// unreachable if we insert the wildcard here
-		if len(n.children) > 0 {
-			panic("wildcard route conflicts with existing children")
-		}
-
 		// find wildcard end (either '/' or path end)
 		end := i + 1
 		for end < max && path[end] != '/' {
@@ -232,6 +339,12 @@ func (n *node) insertChild(numParams uint8, path string, manage Manage) {
 		}
 
 		if c == ':' { // param
+			// A param child may sit alongside static children, but not
+			// another wildcard
+			if n.wildChild {
+				panic("wildcard route conflicts with existing wildcard")
+			}
+
 			// split path at the beginning of the wildcard
 			if i > 0 {
 				n.path = path[offset:i]
@@ -242,7 +355,11 @@ func (n *node) insertChild(numParams uint8, path string, manage Manage) {
 				nType:     param,
 				maxParams: numParams,
 			}
-			n.children = []*node{child}
+			child.key, child.constraint = splitParam(path[i:end])
+			if child.key == "" {
+				panic("wildcards must be named with a non-empty name")
+			}
+			n.children = append(n.children, child)
 			n.wildChild = true
 			n = child
 			n.priority++
@@ -262,7 +379,16 @@ func (n *node) insertChild(numParams uint8, path string, manage Manage) {
 				n = child
 			}
 
+			// continue after the wildcard, skipping any constraint
+			i = end - 1
+
 		} else { // catchAll
+			// Check if this Node existing children which would be
+			// unreachable if we insert the catchAll here
+			if len(n.children) > 0 {
+				panic("wildcard route conflicts with existing children")
+			}
+
 			if end != max || numParams > 1 {
 				panic("catch-all routes are only allowed at the end of the path")
 			}
@@ -315,33 +441,50 @@ func (n *node) insertChild(numParams uint8, path string, manage Manage) {
 // made if a handle exists with an extra (without the) trailing slash for the
 // given path.
 func (n *node) getValue(path string) (manage Manage, p Params, tsr bool) {
+	return n.find(path, nil)
+}
+
+// find walks the tree for getValue, saving wildcard values to the provided
+// Params. Static children take priority over a param child, and the param
+// child is tried when no handle is found below a matching static child.
+func (n *node) find(path string, ps Params) (manage Manage, p Params, tsr bool) {
+	p = ps
 walk: // Outer loop for walking the tree
 	for {
 		if len(path) > len(n.path) {
 			if path[:len(n.path)] == n.path {
 				path = path[len(n.path):]
-				// If this node does not have a wildcard (param or catchAll)
-				// child,  we can just look up the next child node and continue
-				// to walk down the tree
-				if !n.wildChild {
-					c := path[0]
-					for i, index := range n.indices {
-						if c == index {
+				// Look up the next static child node and continue to walk
+				// down the tree. If this node also has a wildcard child, walk
+				// the static child separately, so we can backtrack to the
+				// wildcard child when nothing is found.
+				c := path[0]
+				for i, index := range n.indices {
+					if c == index {
+						if !n.wildChild {
 							n = n.children[i]
 							continue walk
 						}
+
+						m, sp, stsr := n.children[i].find(path, p)
+						if m != nil {
+							return m, sp, false
+						}
+						tsr = stsr
+						break
 					}
+				}
 
+				if !n.wildChild {
 					// Nothing found.
 					// We can recommend to redirect to the same URL without a
 					// trailing slash if a leaf exists for that path.
-					tsr = (path == "/" && n.manage != nil)
+					tsr = tsr || (path == "/" && n.manage != nil)
 					return
-
 				}
 
 				// handle wildcard child
-				n = n.children[0]
+				n = n.children[len(n.children)-1]
 				switch n.nType {
 				case param:
 					// find param end (either '/' or path end)
@@ -350,6 +493,11 @@ walk: // Outer loop for walking the tree
 						end++
 					}
 
+					// a param not matching its constraint is not found
+					if n.constraint != nil && !n.constraint.MatchString(path[:end]) {
+						return
+					}
+
 					// save param value
 					if p == nil {
 						// lazy allocation
@@ -357,7 +505,7 @@ walk: // Outer loop for walking the tree
 					}
 					i := len(p)
 					p = p[:i+1] // expand slice within preallocated capacity
-					p[i].Key = n.path[1:]
+					p[i].Key = n.key
 					p[i].Value = path[:end]
 
 					// we need to go deeper!
@@ -369,7 +517,7 @@ walk: // Outer loop for walking the tree
 						}
 
 						// ... but we can't
-						tsr = (len(path) == end+1)
+						tsr = tsr || (len(path) == end+1)
 						return
 					}
 
@@ -379,7 +527,7 @@ walk: // Outer loop for walking the tree
 						// No handle found. Check if a handle for this path + a
 						// trailing slash exists for TSR recommendation
 						n = n.children[0]
-						tsr = (n.path == "/" && n.manage != nil)
+						tsr = tsr || (n.path == "/" && n.manage != nil)
 					}
 
 					return
@@ -414,7 +562,7 @@ walk: // Outer loop for walking the tree
 			for i, index := range n.indices {
 				if index == '/' {
 					n = n.children[i]
-					tsr = (n.path == "/" && n.manage != nil) ||
+					tsr = tsr || (n.path == "/" && n.manage != nil) ||
 						(n.nType == catchAll && n.children[0].manage != nil)
 					return
 				}
@@ -425,13 +573,24 @@ walk: // Outer loop for walking the tree
 
 		// Nothing found. We can recommend to redirect to the same URL with an
 		// extra trailing slash if a leaf exists for that path
-		tsr = (path == "/") ||
+		tsr = tsr || (path == "/") ||
 			(len(n.path) == len(path)+1 && n.path[len(path)] == '/' &&
 				path == n.path[:len(n.path)-1] && n.manage != nil)
 		return
 	}
 }
 
+// walk calls fn with the full path of every node in the tree with a Manage.
+func (n *node) walk(prefix string, fn func(path string, n *node)) {
+	prefix += n.path
+	if n.manage != nil {
+		fn(prefix, n)
+	}
+	for _, child := range n.children {
+		child.walk(prefix, fn)
+	}
+}
+
 // Makes a case-insensitive lookup of the given path and tries to find a handler.
 // It can optionally also fix trailing slashes.
 // It returns the case-corrected path and a bool indicating wether the lookup
@@ -445,29 +604,28 @@ func (n *node) findCaseInsensitivePath(path string, fixTrailingSlash bool) (ciPa
 		ciPath = append(ciPath, n.path...)
 
 		if len(path) > 0 {
-			// If this node does not have a wildcard (param or catchAll) child,
-			// we can just look up the next child node and continue to walk down
-			// the tree
-			if !n.wildChild {
-				r := unicode.ToLower(rune(path[0]))
-				for i, index := range n.indices {
-					// must use recursive approach since both index and
-					// ToLower(index) could exist. We must check both.
-					if r == unicode.ToLower(rune(index)) {
-						out, found := n.children[i].findCaseInsensitivePath(path, fixTrailingSlash)
-						if found {
-							return append(ciPath, out...), true
-						}
+			// Look up the next static child node and continue to walk down
+			// the tree, before any wildcard (param or catchAll) child
+			r := unicode.ToLower(rune(path[0]))
+			for i, index := range n.indices {
+				// must use recursive approach since both index and
+				// ToLower(index) could exist. We must check both.
+				if r == unicode.ToLower(rune(index)) {
+					out, found := n.children[i].findCaseInsensitivePath(path, fixTrailingSlash)
+					if found {
+						return append(ciPath, out...), true
 					}
 				}
+			}
 
+			if !n.wildChild {
 				// Nothing found. We can recommend to redirect to the same URL
 				// without a trailing slash if a leaf exists for that path
 				found = (fixTrailingSlash && path == "/" && n.manage != nil)
 				return
 
 			} else {
-				n = n.children[0]
+				n = n.children[len(n.children)-1]
 
 				switch n.nType {
 				case param:
@@ -477,6 +635,10 @@ func (n *node) findCaseInsensitivePath(path string, fixTrailingSlash bool) (ciPa
 						k++
 					}
 
+					if n.constraint != nil && !n.constraint.MatchString(path[:k]) {
+						return
+					}
+
 					// add param value to case insensitive path
 					ciPath = append(ciPath, path[:k]...)
 
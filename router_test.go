@@ -120,6 +120,9 @@ func TestCountParams(t *testing.T) {
 	if countParams("/path/:param1/static/*catch-all") != 2 {
 		t.Fail()
 	}
+	if countParams("/path/:param1{[a-z]*}/:param2{int}") != 2 {
+		t.Fail()
+	}
 	if countParams(strings.Repeat("/:param", 256)) != 255 {
 		t.Fail()
 	}
@@ -207,6 +210,53 @@ func TestTreeWildcard(t *testing.T) {
 	checkMaxParams(t, tree)
 }
 
+func TestTreeParamConstraints(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/user/:id{int}",
+		"/user/:id{int}/posts/:slug{[a-z-]+}",
+		"/item/:uuid{uuid}",
+		"/zip/:code{[0-9]{5}}",
+		"/files/:name{[a-z]+\\.txt}/*filepath",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	//printChildren(tree, "")
+
+	checkRequests(t, tree, testRequests{
+		{"/user/42", false, "/user/:id{int}", Params{Param{"id", "42"}}},
+		{"/user/-7", false, "/user/:id{int}", Params{Param{"id", "-7"}}},
+		{"/user/abc", true, "", nil},
+		{"/user/42/posts/go-routing", false, "/user/:id{int}/posts/:slug{[a-z-]+}", Params{Param{"id", "42"}, Param{"slug", "go-routing"}}},
+		{"/user/42/posts/Go_Routing", true, "", Params{Param{"id", "42"}}},
+		{"/item/6ba7b810-9dad-11d1-80b4-00c04fd430c8", false, "/item/:uuid{uuid}", Params{Param{"uuid", "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}}},
+		{"/item/6ba7b810", true, "", nil},
+		{"/zip/90210", false, "/zip/:code{[0-9]{5}}", Params{Param{"code", "90210"}}},
+		{"/zip/9021", true, "", nil},
+		{"/files/notes.txt/a/b", false, "/files/:name{[a-z]+\\.txt}/*filepath", Params{Param{"name", "notes.txt"}, Param{"filepath", "/a/b"}}},
+		{"/files/notes.md/a/b", true, "", nil},
+	})
+
+	checkPriorities(t, tree)
+	checkMaxParams(t, tree)
+
+	if out, found := tree.findCaseInsensitivePath("/USER/42", true); !found || string(out) != "/user/42" {
+		t.Errorf("Wrong result for case insensitive constrained route: %s; %t", string(out), found)
+	}
+	if _, found := tree.findCaseInsensitivePath("/USER/abc", true); found {
+		t.Error("Found case insensitive path for a param not matching its constraint")
+	}
+
+	for _, route := range []string{"/:{int}", "/bad/:id{[a-z}", "/unclosed/:id{int"} {
+		if recv := catchPanic(func() { (&node{}).addRoute(route, nil) }); recv == nil {
+			t.Errorf("no panic for invalid constrained route '%s'", route)
+		}
+	}
+}
+
 func catchPanic(testFunc func()) (recv interface{}) {
 	defer func() {
 		recv = recover()
@@ -244,7 +294,8 @@ func testRoutes(t *testing.T, routes []testRoute) {
 func TestTreeWildcardConflict(t *testing.T) {
 	routes := []testRoute{
 		{"/cmd/:tool/:sub", false},
-		{"/cmd/vet", true},
+		{"/cmd/vet", false},
+		{"/cmd/:badvar", true},
 		{"/src/*filepath", false},
 		{"/src/*filepathx", true},
 		{"/src/", true},
@@ -252,12 +303,14 @@ func TestTreeWildcardConflict(t *testing.T) {
 		{"/src1/*filepath", true},
 		{"/src2*filepath", true},
 		{"/search/:query", false},
-		{"/search/invalid", true},
+		{"/search/invalid", false},
+		{"/search/*filepath", true},
 		{"/user_:name", false},
-		{"/user_x", true},
+		{"/user_x", false},
 		{"/user_:name", false},
+		{"/user_:other", true},
 		{"/id:id", false},
-		{"/id/:id", true},
+		{"/id/:id", false},
 	}
 	testRoutes(t, routes)
 }
@@ -265,19 +318,99 @@ func TestTreeWildcardConflict(t *testing.T) {
 func TestTreeChildConflict(t *testing.T) {
 	routes := []testRoute{
 		{"/cmd/vet", false},
-		{"/cmd/:tool/:sub", true},
+		{"/cmd/:tool/:sub", false},
 		{"/src/AUTHORS", false},
 		{"/src/*filepath", true},
 		{"/user_x", false},
-		{"/user_:name", true},
+		{"/user_:name", false},
 		{"/id/:id", false},
-		{"/id:id", true},
-		{"/:id", true},
+		{"/id:id", false},
+		{"/:id", false},
 		{"/*filepath", true},
 	}
 	testRoutes(t, routes)
 }
 
+func TestTreeStaticAndParam(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/users/:id",
+		"/users/new",
+		"/users/:id/edit",
+		"/users/new/confirm",
+		"/users/newest/",
+		"/a/:x/b",
+		"/a/c/d",
+		"/:page",
+		"/about",
+		"/item/new",
+		"/item/:id{int}",
+	}
+	for _, route := range routes {
+		recv := catchPanic(func() {
+			tree.addRoute(route, fakeHandler(route))
+		})
+		if recv != nil {
+			t.Fatalf("panic inserting route '%s': %v", route, recv)
+		}
+	}
+
+	//printChildren(tree, "")
+
+	checkRequests(t, tree, testRequests{
+		{"/users/new", false, "/users/new", nil},
+		{"/users/42", false, "/users/:id", Params{Param{"id", "42"}}},
+		{"/users/ne", false, "/users/:id", Params{Param{"id", "ne"}}},
+		{"/users/newbie", false, "/users/:id", Params{Param{"id", "newbie"}}},
+		{"/users/new/edit", false, "/users/:id/edit", Params{Param{"id", "new"}}},
+		{"/users/new/confirm", false, "/users/new/confirm", nil},
+		{"/users/newest/", false, "/users/newest/", nil},
+		{"/users/newest/edit", false, "/users/:id/edit", Params{Param{"id", "newest"}}},
+		{"/a/c/d", false, "/a/c/d", nil},
+		{"/a/c/b", false, "/a/:x/b", Params{Param{"x", "c"}}},
+		{"/about", false, "/about", nil},
+		{"/abo", false, "/:page", Params{Param{"page", "abo"}}},
+		{"/item/new", false, "/item/new", nil},
+		{"/item/7", false, "/item/:id{int}", Params{Param{"id", "7"}}},
+		{"/item/old", true, "", Params{Param{"page", "item"}}},
+	})
+
+	checkPriorities(t, tree)
+	checkMaxParams(t, tree)
+
+	tsrRoutes := [...]string{
+		"/users/new/confirm/",
+		"/users/42/",
+		"/about/",
+	}
+	for _, route := range tsrRoutes {
+		handler, _, tsr := tree.getValue(route)
+		if handler != nil {
+			t.Errorf("non-nil handler for TSR route '%s", route)
+		} else if !tsr {
+			t.Errorf("expected TSR recommendation for route '%s'", route)
+		}
+	}
+
+	ciRoutes := []struct {
+		in  string
+		out string
+	}{
+		{"/USERS/NEW", "/users/new"},
+		{"/USERS/Gopher", "/users/Gopher"},
+		{"/USERS/NEW/EDIT", "/users/NEW/edit"},
+		{"/USERS/NEWEST", "/users/newest/"},
+		{"/A/C/B", "/a/C/b"},
+	}
+	for _, ci := range ciRoutes {
+		out, found := tree.findCaseInsensitivePath(ci.in, true)
+		if !found || string(out) != ci.out {
+			t.Errorf("Wrong result for case insensitive route '%s': %s; %t", ci.in, string(out), found)
+		}
+	}
+}
+
 func TestTreeDupliatePath(t *testing.T) {
 	tree := &node{}
 
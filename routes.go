@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+)
+
+type (
+	// Route describes a Manage registered to the engine: the method, full path
+	// pattern, prefix of the Group taking the route (empty for a route not taken
+	// by a Group), name of the handler, and the route name, if any.
+	Route struct {
+		Method  string
+		Path    string
+		Group   string
+		Handler string
+		Name    string
+	}
+
+	routesByPath []Route
+)
+
+func (r routesByPath) Len() int      { return len(r) }
+func (r routesByPath) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r routesByPath) Less(i, j int) bool {
+	if r[i].Path == r[j].Path {
+		return r[i].Method < r[j].Method
+	}
+	return r[i].Path < r[j].Path
+}
+
+// Routes walks the routing tree of each method, returning every registered
+// route ordered by path and method.
+func (e *Engine) Routes() []Route {
+	var rs []Route
+	for method, root := range e.trees {
+		root.walk("", func(path string, n *node) {
+			if r, ok := e.routes[method+" "+path]; ok {
+				rs = append(rs, *r)
+			} else {
+				rs = append(rs, Route{Method: method, Path: path, Handler: handlerName(n.manage)})
+			}
+		})
+	}
+	sort.Sort(routesByPath(rs))
+	return rs
+}
+
+func handlerName(fn interface{}) string {
+	if f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()); f != nil {
+		return f.Name()
+	}
+	return string(unknown)
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"golang.org/x/net/context"
+)
+
+type (
+	// Server serves an Engine with the read, write and idle timeouts of the
+	// engine configuration, shutting down gracefully on SIGTERM or SIGINT.
+	// Lifecycle events are published to engine.Events as EventServer, or
+	// EventShutdown for server-shutdown:
+	//
+	//	server-start <addr>       the server is accepting connections
+	//	server-redirect <addr>    the server is redirecting HTTP to HTTPS
+	//	server-cert-reload        the TLS certificate files were reloaded
+	//	server-cert-error <err>   the TLS certificate files failed to reload
+	//	server-signal <signal>    a shutdown signal was received
+	//	server-shutdown           the server stopped accepting connections
+	//	server-drained            every in-flight request finished
+	//	server-closed             the grace period passed, connections were closed
+	Server struct {
+		engine   *Engine
+		srv      *http.Server
+		redirect *http.Server
+		mu       sync.Mutex
+		shutdown sync.Once
+		err      error
+	}
+)
+
+// NewServer returns a Server for the engine on the address.
+func NewServer(addr string, engine *Engine) *Server {
+	return &Server{
+		engine: engine,
+		srv: &http.Server{
+			Addr:         addr,
+			Handler:      engine,
+			ReadTimeout:  engine.ReadTimeout,
+			WriteTimeout: engine.WriteTimeout,
+			IdleTimeout:  engine.IdleTimeout,
+		},
+	}
+}
+
+// ListenAndServe listens on the Server address and serves the engine, see
+// Serve.
+func (s *Server) ListenAndServe() error {
+	l, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve serves the engine on the listener until SIGTERM or SIGINT is received,
+// or Shutdown is called, then returns once the shutdown has finished, with
+// in-flight requests drained or the engine GracePeriod passed. Serve returns
+// nil after a graceful shutdown, and otherwise any error serving or shutting
+// down.
+func (s *Server) Serve(l net.Listener) error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(sigs)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.srv.Serve(l)
+	}()
+	s.engine.publish(EventServer, fmt.Sprintf("server-start %s", l.Addr()), nil)
+
+	select {
+	case err := <-errs:
+		if err == http.ErrServerClosed {
+			// wait for the Shutdown closing the server to finish
+			return s.Shutdown()
+		}
+		return err
+	case sig := <-sigs:
+		s.engine.publish(EventServer, fmt.Sprintf("server-signal %s", sig), nil)
+		return s.Shutdown()
+	}
+}
+
+// Shutdown stops the Server accepting connections, closes any HTTPS redirect
+// listener, and waits the engine GracePeriod for in-flight requests to finish
+// before closing any remaining connections, returning context.DeadlineExceeded
+// if it had to close them. After a graceful shutdown, Shutdown waits the
+// remainder of the GracePeriod for the engine queues to drain, returning
+// context.DeadlineExceeded if they did not. The Server shuts down once, and
+// later or concurrent calls wait for it and return the same error.
+func (s *Server) Shutdown() error {
+	s.shutdown.Do(func() {
+		s.err = s.shutdownGracefully()
+	})
+	return s.err
+}
+
+func (s *Server) shutdownGracefully() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.engine.GracePeriod)
+	defer cancel()
+	s.engine.publish(EventShutdown, "server-shutdown", nil)
+	s.closeRedirect()
+	if err := s.srv.Shutdown(ctx); err != nil {
+		s.srv.Close()
+		s.engine.publish(EventServer, "server-closed", nil)
+		return err
+	}
+	s.engine.publish(EventServer, "server-drained", nil)
+	return s.engine.Flush(ctx)
+}
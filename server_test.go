@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+type serverEvents struct {
+	sync.Mutex
+	events map[string]bool
+}
+
+func (s *serverEvents) subscribe(e *Engine) {
+	s.events = make(map[string]bool)
+	sub := e.Events.Subscribe(100, Block, EventServer, EventShutdown)
+	go func() {
+		for ev := range sub.C {
+			s.Lock()
+			s.events[ev.Message] = true
+			s.Unlock()
+		}
+	}()
+}
+
+func (s *serverEvents) has(event string) bool {
+	for i := 0; i < 100; i++ {
+		s.Lock()
+		ok := s.events[event]
+		s.Unlock()
+		if ok {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
+
+func testServer(e *Engine, sleep time.Duration, t *testing.T) (*Server, chan error, chan *http.Response) {
+	started := make(chan struct{})
+	e.Take("/slow", "GET", func(c context.Context) {
+		close(started)
+		time.Sleep(sleep)
+		currentCtx(c).RW.Write([]byte("drained"))
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(l.Addr().String(), e)
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(l) }()
+
+	responses := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get("http://" + l.Addr().String() + "/slow")
+		if err != nil {
+			responses <- nil
+			return
+		}
+		responses <- resp
+	}()
+	<-started
+	return s, served, responses
+}
+
+func TestServerSignal(t *testing.T) {
+	e, _ := New()
+	var events serverEvents
+	events.subscribe(e)
+	_, served, responses := testServer(e, 50*time.Millisecond, t)
+
+	p, _ := os.FindProcess(os.Getpid())
+	p.Signal(syscall.SIGTERM)
+
+	if err := <-served; err != nil {
+		t.Errorf("Serve should return nil after a graceful shutdown, returned %s", err)
+	}
+	resp := <-responses
+	if resp == nil {
+		t.Fatal("In-flight request should be drained")
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || string(body) != "drained" {
+		t.Errorf("In-flight request should complete, was %d '%s'", resp.StatusCode, body)
+	}
+	for _, event := range []string{"server-signal terminated", "server-shutdown", "server-drained"} {
+		if !events.has(event) {
+			t.Errorf("Server should send %s", event)
+		}
+	}
+}
+
+func TestServerGracePeriod(t *testing.T) {
+	e, _ := New(GracePeriod(20 * time.Millisecond))
+	var events serverEvents
+	events.subscribe(e)
+	s, served, responses := testServer(e, 500*time.Millisecond, t)
+
+	if err := s.Shutdown(); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown should exceed the grace period, returned %v", err)
+	}
+	if err := <-served; err != context.DeadlineExceeded {
+		t.Errorf("Serve should return the error of Shutdown, returned %v", err)
+	}
+	if resp := <-responses; resp != nil {
+		t.Errorf("Request outlasting the grace period should have its connection closed")
+	}
+	if !events.has("server-closed") {
+		t.Errorf("Server should send server-closed")
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	e, _ := New()
+	var handled int32
+	e.Use(func(c context.Context) {
+		currentCtx(c).Next()
+		atomic.StoreInt32(&handled, 1)
+	})
+	s, served, responses := testServer(e, 50*time.Millisecond, t)
+
+	go s.Shutdown()
+	if err := <-served; err != nil {
+		t.Errorf("Serve should return nil after a graceful Shutdown, returned %s", err)
+	}
+	if atomic.LoadInt32(&handled) != 1 {
+		t.Errorf("Serve should return once the in-flight request is drained")
+	}
+	if resp := <-responses; resp == nil || resp.StatusCode != 200 {
+		t.Errorf("In-flight request should complete")
+	} else {
+		resp.Body.Close()
+	}
+}
+
+func TestServerShutdownQueues(t *testing.T) {
+	e, _ := New(GracePeriod(50 * time.Millisecond))
+	stuck := make(chan struct{})
+	defer close(stuck)
+	e.AddQueue("stuck", func(string) { <-stuck }, QueueOptions{Workers: 1, Buffer: 1})
+	e.Send("stuck", "message")
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- NewServer("127.0.0.1:0", e).Shutdown() }()
+	select {
+	case err := <-shutdown:
+		if err != context.DeadlineExceeded {
+			t.Errorf("Shutdown should exceed the grace period flushing a stuck queue, returned %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown should not wait past the grace period for a stuck queue")
+	}
+}
+
+func TestRunGracePeriod(t *testing.T) {
+	e, _ := New(GracePeriod(10 * time.Millisecond))
+	started := e.Events.Subscribe(1, Drop, EventServer)
+	defer started.Unsubscribe()
+	handling := make(chan struct{})
+	e.Take("/slow", "GET", func(c context.Context) {
+		close(handling)
+		time.Sleep(200 * time.Millisecond)
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	ran := make(chan interface{}, 1)
+	go func() {
+		defer func() { ran <- recover() }()
+		e.Run(addr)
+	}()
+	<-started.C
+	go http.Get("http://" + addr + "/slow")
+	<-handling
+
+	p, _ := os.FindProcess(os.Getpid())
+	p.Signal(syscall.SIGTERM)
+	if rcv := <-ran; rcv != nil {
+		t.Errorf("Run should return after a shutdown exceeding the grace period, panicked %v", rcv)
+	}
+}
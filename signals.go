@@ -3,31 +3,50 @@ package engine
 import (
 	"fmt"
 	"log"
-)
+	"sync"
+	"sync/atomic"
 
-var (
-	EnginePanic = []byte("engine-panic")
+	"golang.org/x/net/context"
 )
 
 type (
-	// Signal denotes a byte signal
-	Signal []byte
-
-	// Signals is a channel for Signal
-	Signals chan Signal
-
-	queue func(string)
+	// QueueOptions size a Queue. With no Workers, messages are handled in the
+	// call to Send. Otherwise Workers goroutines handle messages from a buffer
+	// of Buffer messages, applying the Overflow policy when it is full. With no
+	// Buffer, a message is handed to an idle worker, or the policy applied.
+	QueueOptions struct {
+		Workers  int
+		Buffer   int
+		Overflow Overflow
+	}
 
-	queues map[string]queue
+	// Queue handles the messages sent to it by name with Engine.Send.
+	Queue struct {
+		dropped   uint64
+		processed uint64
+		name      string
+		handler   func(string)
+		opts      QueueOptions
+		c         chan string
+		mu        sync.Mutex
+		pending   int
+		idle      chan struct{}
+		closeMu   sync.RWMutex
+		closed    bool
+		workers   sync.WaitGroup
+	}
 )
 
-// A simple Signal queue outputting everything emitted to engine.Signals.
-func SignalQueue(e *Engine) {
+// MessageEvents sends the message of every event published to engine.Events
+// to Message, until the returned Subscription is unsubscribed.
+func MessageEvents(e *Engine) *Subscription {
+	s := e.Events.Subscribe(1000, Drop)
 	go func() {
-		for sig := range e.Signals {
-			e.Message(fmt.Sprintf("%s", sig))
+		for ev := range s.C {
+			e.Message(fmt.Sprintf("%s %s", ev.Kind, ev.Message))
 		}
 	}()
+	return s
 }
 
 // Message goes directly to a logger, if enabled.
@@ -37,33 +56,228 @@ func (e *Engine) Message(message string) {
 	}
 }
 
-// PanicMessage goes to a standard and unavaoidable log, then emits a signal.
+// PanicMessage goes to a standard and unavaoidable log, then publishes an
+// EventPanic.
 func (e *Engine) PanicMessage(message string) {
 	log.Println(fmt.Errorf("[ENGINE] %s", message))
-	e.Signals <- EnginePanic
-	//e.Signals <- []byte(message)
+	e.publish(EventPanic, message, nil)
 }
 
-// Emit takes a string that goes as []byte directly to engine.Signals
+// Emit publishes the message to engine.Events as an EventMessage.
 func (e *Engine) Emit(message string) {
-	e.Signals <- []byte(message)
+	e.publish(EventMessage, message, nil)
+}
+
+// AddQueue registers a Queue with the name for messages sent with Send to be
+// handled by the handler, e.g. shipping panics to a file with the name
+// "panic", or counting the recorder line of every request served with the
+// name "recorder". Any existing Queue with the name is replaced and closed, returning
+// once its buffered messages are handled.
+func (e *Engine) AddQueue(name string, handler func(string), opts QueueOptions) *Queue {
+	q := &Queue{name: name, handler: handler, opts: opts}
+	if opts.Workers > 0 {
+		q.c = make(chan string, opts.Buffer)
+		q.workers.Add(opts.Workers)
+		for i := 0; i < opts.Workers; i++ {
+			go q.work()
+		}
+	}
+	e.queuesMu.Lock()
+	if e.queues == nil {
+		e.queues = make(map[string]*Queue)
+	}
+	old := e.queues[name]
+	e.queues[name] = q
+	e.queuesMu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return q
+}
+
+// RemoveQueue removes and closes the Queue registered with the name, if any,
+// returning once its buffered messages are handled. Later messages sent with
+// the name are emitted.
+func (e *Engine) RemoveQueue(name string) {
+	e.queuesMu.Lock()
+	q := e.queues[name]
+	delete(e.queues, name)
+	e.queuesMu.Unlock()
+	if q != nil {
+		q.Close()
+	}
 }
 
-// Send sends a message to the specified queue.
+// Queue returns the Queue registered with the name, or nil.
+func (e *Engine) Queue(name string) *Queue {
+	e.queuesMu.RLock()
+	defer e.queuesMu.RUnlock()
+	return e.queues[name]
+}
+
+// Send sends a message to the specified queue, or emits the message without
+// the queue.
 func (e *Engine) Send(queue string, message string) {
-	go func() {
-		if q, ok := e.Queues[queue]; ok {
-			q(message)
-		} else {
+	for {
+		q := e.Queue(queue)
+		if q == nil {
 			e.Emit(message)
+			return
+		}
+		// a Queue closed while replaced or removed refuses the message, to
+		// be sent again to any Queue now registered with the name
+		if q.send(message) {
+			return
+		}
+	}
+}
+
+// Flush waits until every message sent to every Queue has been handled or
+// dropped, or until the context.Context is done, returning its error.
+func (e *Engine) Flush(ctx context.Context) error {
+	e.queuesMu.RLock()
+	qs := make([]*Queue, 0, len(e.queues))
+	for _, q := range e.queues {
+		qs = append(qs, q)
+	}
+	e.queuesMu.RUnlock()
+	for _, q := range qs {
+		if err := q.Flush(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Engine) defaultqueues() {
+	e.AddQueue("message", e.Message, QueueOptions{})
+	e.AddQueue("panic", e.PanicMessage, QueueOptions{})
+	e.AddQueue("emit", e.Emit, QueueOptions{})
+}
+
+// send handles or buffers the message, or applies the Overflow policy,
+// returning false when the Queue is closed.
+func (q *Queue) send(message string) bool {
+	q.closeMu.RLock()
+	defer q.closeMu.RUnlock()
+	if q.closed {
+		return false
+	}
+	if q.c == nil {
+		q.handle(message)
+		return true
+	}
+	q.add(1)
+	switch q.opts.Overflow {
+	case Block:
+		q.c <- message
+	case DropOldest:
+		for {
+			select {
+			case q.c <- message:
+				return true
+			default:
+			}
+			if cap(q.c) == 0 {
+				// without a buffer there is no older message to drop, and
+				// the message is dropped unless a worker is idle
+				q.drop()
+				return true
+			}
+			select {
+			case <-q.c:
+				q.drop()
+			default:
+			}
+		}
+	default:
+		select {
+		case q.c <- message:
+		default:
+			q.drop()
+		}
+	}
+	return true
+}
+
+func (q *Queue) work() {
+	defer q.workers.Done()
+	for message := range q.c {
+		q.handle(message)
+		q.add(-1)
+	}
+}
+
+// Close stops the Queue accepting messages, returning once its buffered
+// messages are handled and its workers have stopped. A Queue is closed by
+// Engine.RemoveQueue, or when replaced with Engine.AddQueue.
+func (q *Queue) Close() {
+	q.closeMu.Lock()
+	if q.closed {
+		q.closeMu.Unlock()
+		return
+	}
+	q.closed = true
+	if q.c != nil {
+		close(q.c)
+	}
+	q.closeMu.Unlock()
+	q.workers.Wait()
+}
+
+func (q *Queue) handle(message string) {
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			log.Println(fmt.Errorf("[ENGINE] queue %s panicked: %v", q.name, rcv))
 		}
 	}()
+	q.handler(message)
+	atomic.AddUint64(&q.processed, 1)
 }
 
-func (e *Engine) defaultqueues() queues {
-	q := make(queues)
-	q["message"] = e.Message
-	q["panic"] = e.PanicMessage
-	q["emit"] = e.Emit
-	return q
+func (q *Queue) drop() {
+	atomic.AddUint64(&q.dropped, 1)
+	q.add(-1)
+}
+
+// add counts n messages pending, closing the idle channel of any Flush once
+// none are pending.
+func (q *Queue) add(n int) {
+	q.mu.Lock()
+	if q.pending == 0 {
+		q.idle = make(chan struct{})
+	}
+	q.pending += n
+	if q.pending == 0 {
+		close(q.idle)
+	}
+	q.mu.Unlock()
+}
+
+// Flush waits until every message sent to the Queue has been handled or
+// dropped, or until the context.Context is done, returning its error.
+func (q *Queue) Flush(ctx context.Context) error {
+	q.mu.Lock()
+	if q.pending == 0 {
+		q.mu.Unlock()
+		return nil
+	}
+	idle := q.idle
+	q.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Dropped returns the number of messages dropped by the Overflow policy.
+func (q *Queue) Dropped() uint64 {
+	return atomic.LoadUint64(&q.dropped)
+}
+
+// Processed returns the number of messages handled.
+func (q *Queue) Processed() uint64 {
+	return atomic.LoadUint64(&q.processed)
 }
@@ -2,7 +2,12 @@ package engine
 
 import (
 	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"golang.org/x/net/context"
 )
@@ -12,15 +17,18 @@ func testSignal(method string, t *testing.T) {
 
 	e, _ := New()
 
-	testsignalq := func() {
+	testsignalq := func() *Subscription {
+		s := e.Events.Subscribe(100, Drop)
 		go func() {
-			for msg := range e.Signals {
-				fmt.Printf("test: %s\n", msg)
+			for ev := range s.C {
+				fmt.Printf("test: %s %s\n", ev.Kind, ev.Message)
 			}
 		}()
+		return s
 	}
 
-	testsignalq()
+	s := testsignalq()
+	defer s.Unsubscribe()
 
 	testqueue := func(s string) {
 		if s != "SENT" {
@@ -28,7 +36,7 @@ func testSignal(method string, t *testing.T) {
 		}
 	}
 
-	e.Queues["testqueue"] = testqueue
+	e.AddQueue("testqueue", testqueue, QueueOptions{})
 
 	e.Take("/test_signal_sent", method, func(c context.Context) {
 		sent = true
@@ -53,3 +61,137 @@ func TestSignal(t *testing.T) {
 	testSignal("OPTIONS", t)
 	testSignal("HEAD", t)
 }
+
+func TestAddQueue(t *testing.T) {
+	e, _ := New()
+	var handled int64
+	q := e.AddQueue("count", func(string) {
+		atomic.AddInt64(&handled, 1)
+	}, QueueOptions{Workers: 4, Buffer: 100, Overflow: Block})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				e.Send("count", "line")
+			}
+		}()
+	}
+	wg.Wait()
+	e.Flush(context.Background())
+
+	if n := atomic.LoadInt64(&handled); n != 1000 || q.Processed() != 1000 || q.Dropped() != 0 {
+		t.Errorf("Queue should handle 1000 messages, handled %d, processed %d, dropped %d", n, q.Processed(), q.Dropped())
+	}
+	if e.Queue("count") != q || e.Queue("missing") != nil {
+		t.Errorf("Queue should return the registered Queue by name")
+	}
+}
+
+func testQueueOverflow(overflow Overflow, t *testing.T) ([]string, *Queue) {
+	e, _ := New()
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	var mu sync.Mutex
+	var handled []string
+	q := e.AddQueue("slow", func(m string) {
+		once.Do(func() { close(started) })
+		<-release
+		mu.Lock()
+		handled = append(handled, m)
+		mu.Unlock()
+	}, QueueOptions{Workers: 1, Buffer: 2, Overflow: overflow})
+
+	e.Send("slow", "0")
+	<-started
+	for _, m := range []string{"1", "2", "3", "4"} {
+		e.Send("slow", m)
+	}
+	close(release)
+	e.Flush(context.Background())
+	return handled, q
+}
+
+func TestQueueOverflow(t *testing.T) {
+	handled, q := testQueueOverflow(Drop, t)
+	if fmt.Sprint(handled) != "[0 1 2]" || q.Dropped() != 2 {
+		t.Errorf("Drop should handle [0 1 2], handled %v, dropped %d", handled, q.Dropped())
+	}
+	handled, q = testQueueOverflow(DropOldest, t)
+	if fmt.Sprint(handled) != "[0 3 4]" || q.Dropped() != 2 {
+		t.Errorf("DropOldest should handle [0 3 4], handled %v, dropped %d", handled, q.Dropped())
+	}
+}
+
+func TestQueueUnbuffered(t *testing.T) {
+	for _, overflow := range []Overflow{Drop, DropOldest} {
+		e, _ := New()
+		q := e.AddQueue("unbuffered", func(string) {}, QueueOptions{Workers: 1, Overflow: overflow})
+		sent := uint64(0)
+		for deadline := time.Now().Add(time.Second); q.Processed() == 0 && time.Now().Before(deadline); sent++ {
+			e.Send("unbuffered", "message")
+			time.Sleep(time.Millisecond)
+		}
+		e.Flush(context.Background())
+		if q.Processed() == 0 || q.Processed()+q.Dropped() != sent {
+			t.Errorf("Queue without a buffer should hand messages to an idle worker, processed %d and dropped %d of %d", q.Processed(), q.Dropped(), sent)
+		}
+	}
+}
+
+func TestQueueReplace(t *testing.T) {
+	e, _ := New()
+	var handled int64
+	count := func(string) { atomic.AddInt64(&handled, 1) }
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		e.AddQueue("replaced", count, QueueOptions{Workers: 4, Buffer: 10})
+		for j := 0; j < 10; j++ {
+			e.Send("replaced", "message")
+		}
+	}
+	e.RemoveQueue("replaced")
+	if n := atomic.LoadInt64(&handled); n != 100 {
+		t.Errorf("Replaced and removed queues should handle their messages, handled %d of 100", n)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Replaced and removed queues should stop their workers, %d goroutines left of %d", after, before)
+	}
+
+	s := e.Events.Subscribe(1, Drop, EventMessage)
+	defer s.Unsubscribe()
+	e.Send("replaced", "emitted")
+	if ev := <-s.C; ev.Message != "emitted" || e.Queue("replaced") != nil {
+		t.Errorf("Messages to a removed queue should be emitted, was %q", ev.Message)
+	}
+}
+
+func TestRecorderQueue(t *testing.T) {
+	e, _ := New()
+	e.Take("/recorded", "GET", func(c context.Context) {})
+	var lines []string
+	e.AddQueue("recorder", func(line string) { lines = append(lines, line) }, QueueOptions{})
+
+	PerformRequest(e, "GET", "/recorded")
+	PerformRequest(e, "GET", "/missing")
+	if len(lines) != 2 || !strings.Contains(lines[0], "/recorded") || !strings.HasPrefix(lines[1], "recorder") {
+		t.Errorf("The recorder queue should receive the recorder line of every request, received %q", lines)
+	}
+}
+
+func TestQueuePanic(t *testing.T) {
+	e, _ := New()
+	q := e.AddQueue("panics", func(m string) {
+		if m == "panic" {
+			panic("queue panic")
+		}
+	}, QueueOptions{Workers: 1, Buffer: 10})
+	e.Send("panics", "panic")
+	e.Send("panics", "ok")
+	e.Flush(context.Background())
+	if q.Processed() != 1 {
+		t.Errorf("Queue should keep handling messages after a panic, processed %d", q.Processed())
+	}
+}
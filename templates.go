@@ -0,0 +1,212 @@
+package engine
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Templates are html/template sets loaded from an http.FileSystem. Every
+	// page template in the file system is parsed into its own set, together
+	// with the layout template and every template in the partials directory.
+	// With a layout, rendering a page executes the layout, which includes the
+	// templates the page defines, e.g. {{template "content" .}}.
+	Templates struct {
+		fs       http.FileSystem
+		layout   string
+		partials string
+		funcs    template.FuncMap
+		mu       sync.RWMutex
+		sets     map[string]*template.Template
+		modtimes map[string]time.Time
+	}
+)
+
+// NewTemplates loads Templates from the file system, with an optional layout
+// file and partials directory (empty for none), and any template functions.
+// Template names are the slash separated file paths without a leading slash,
+// e.g. "users/show.html" or "partials/nav.html".
+func NewTemplates(fs http.FileSystem, layout string, partials string, funcs template.FuncMap) (*Templates, error) {
+	t := &Templates{
+		fs:       fs,
+		layout:   strings.TrimPrefix(layout, "/"),
+		partials: strings.Trim(partials, "/"),
+		funcs:    funcs,
+	}
+	if err := t.Load(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Load (re)loads every template set from the file system.
+func (t *Templates) Load() error {
+	modtimes, err := t.files()
+	if err != nil {
+		return err
+	}
+
+	shared := make(map[string]string)
+	pages := make(map[string]string)
+	for name := range modtimes {
+		content, err := t.read(name)
+		if err != nil {
+			return err
+		}
+		if name == t.layout || t.isPartial(name) {
+			shared[name] = content
+		} else {
+			pages[name] = content
+		}
+	}
+
+	if t.layout != "" {
+		if _, ok := shared[t.layout]; !ok {
+			return newError("template layout %s does not exist", t.layout)
+		}
+	}
+
+	// the layout and partials are parsed before the page, so the definitions
+	// of the page replace any block defaults of the layout
+	sets := make(map[string]*template.Template)
+	for name, content := range pages {
+		set := template.New(name).Funcs(t.funcs)
+		for sname, scontent := range shared {
+			if _, err := set.New(sname).Parse(scontent); err != nil {
+				return err
+			}
+		}
+		if _, err := set.Parse(content); err != nil {
+			return err
+		}
+		sets[name] = set
+	}
+
+	t.mu.Lock()
+	t.sets, t.modtimes = sets, modtimes
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *Templates) isPartial(name string) bool {
+	return t.partials != "" && strings.HasPrefix(name, t.partials+"/")
+}
+
+// files returns the modification time of every file in the file system.
+func (t *Templates) files() (map[string]time.Time, error) {
+	modtimes := make(map[string]time.Time)
+	return modtimes, t.readDir("/", modtimes)
+}
+
+func (t *Templates) readDir(dir string, modtimes map[string]time.Time) error {
+	f, err := t.fs.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	infos, err := f.Readdir(-1)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		p := path.Join(dir, info.Name())
+		if info.IsDir() {
+			if err := t.readDir(p, modtimes); err != nil {
+				return err
+			}
+		} else {
+			modtimes[p[1:]] = info.ModTime()
+		}
+	}
+	return nil
+}
+
+func (t *Templates) read(name string) (string, error) {
+	f, err := t.fs.Open("/" + name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	return string(b), err
+}
+
+// changed reports whether any file was added, removed or modified since the
+// templates were loaded.
+func (t *Templates) changed() bool {
+	modtimes, err := t.files()
+	if err != nil {
+		return true
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(modtimes) != len(t.modtimes) {
+		return true
+	}
+	for name, modtime := range modtimes {
+		if loaded, ok := t.modtimes[name]; !ok || !loaded.Equal(modtime) {
+			return true
+		}
+	}
+	return false
+}
+
+// Execute writes the named page template with the data to w, executing the
+// layout when the Templates have one. With reload, the templates are first
+// reloaded if any file has changed.
+func (t *Templates) Execute(w io.Writer, name string, data interface{}, reload bool) error {
+	if reload && t.changed() {
+		if err := t.Load(); err != nil {
+			return err
+		}
+	}
+
+	t.mu.RLock()
+	set, ok := t.sets[name]
+	t.mu.RUnlock()
+	if !ok {
+		return newError("template %s does not exist", name)
+	}
+
+	if t.layout != "" {
+		return set.ExecuteTemplate(w, t.layout, data)
+	}
+	return set.ExecuteTemplate(w, name, data)
+}
+
+// SetTemplates attaches Templates to the group, used by Ctx.Render for the
+// group and any sub-groups without their own Templates.
+func (group *Group) SetTemplates(t *Templates) {
+	group.templates = t
+}
+
+func (group *Group) templatesFor() *Templates {
+	for g := group; g != nil; g = g.parent {
+		if g.templates != nil {
+			return g.templates
+		}
+	}
+	return nil
+}
+
+// Render writes the code and the named template, executed with the data, from
+// the Templates of the current group to the response. An error executing the
+// template is attached to the Ctx, and calls the 500 HttpStatus.
+func (c *Ctx) Render(code int, name string, data interface{}) error {
+	t := c.group.templatesFor()
+	if t == nil {
+		return c.renderError(newError("no templates for group %s", c.group.prefix), name)
+	}
+	var buffer bytes.Buffer
+	if err := t.Execute(&buffer, name, data, c.engine.ReloadTemplates); err != nil {
+		return c.renderError(err, name)
+	}
+	return c.Bytes(code, "text/html; charset=utf-8", buffer.Bytes())
+}
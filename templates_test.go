@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func writeTemplates(dir string, files map[string]string, t *testing.T) {
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func templateDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "engine-templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTemplates(dir, map[string]string{
+		"layout.html":       `<html>{{template "partials/nav.html" .}}{{template "content" .}}</html>`,
+		"partials/nav.html": `<nav>{{.Title}}</nav>`,
+		"index.html":        `{{define "content"}}<p>{{upper .Title}}</p>{{end}}`,
+		"users/show.html":   `{{define "content"}}<p>user {{.Title}}</p>{{end}}`,
+	}, t)
+	return dir
+}
+
+func performRender(e *Engine, path string) string {
+	return PerformRequest(e, "GET", path).Body.String()
+}
+
+func TestRenderTemplates(t *testing.T) {
+	dir := templateDir(t)
+	defer os.RemoveAll(dir)
+
+	funcs := template.FuncMap{"upper": strings.ToUpper}
+	tmpl, err := NewTemplates(http.Dir(dir), "layout.html", "partials", funcs)
+	if err != nil {
+		t.Fatalf("NewTemplates returned error: %s", err)
+	}
+
+	e, _ := New()
+	e.SetTemplates(tmpl)
+	users := e.New("/users")
+	e.Take("/", "GET", func(c context.Context) {
+		currentCtx(c).Render(200, "index.html", map[string]string{"Title": "gopher"})
+	})
+	users.Take("/show", "GET", func(c context.Context) {
+		currentCtx(c).Render(200, "users/show.html", map[string]string{"Title": "gopher"})
+	})
+	e.Take("/missing", "GET", func(c context.Context) {
+		currentCtx(c).Render(200, "missing.html", nil)
+	})
+
+	if body := performRender(e, "/"); body != "<html><nav>gopher</nav><p>GOPHER</p></html>" {
+		t.Errorf("Rendered index was '%s'", body)
+	}
+	if body := performRender(e, "/users/show"); body != "<html><nav>gopher</nav><p>user gopher</p></html>" {
+		t.Errorf("Rendered users/show was '%s'", body)
+	}
+	if w := PerformRequest(e, "GET", "/missing"); w.Code != 500 {
+		t.Errorf("Status code rendering a missing template should be 500, was %d", w.Code)
+	}
+}
+
+func TestTemplatesBlock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "engine-templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTemplates(dir, map[string]string{
+		"layout.html": `<body>{{block "content" .}}default{{end}}</body>`,
+		"page.html":   `{{define "content"}}page{{end}}`,
+		"empty.html":  ``,
+	}, t)
+
+	tmpl, err := NewTemplates(http.Dir(dir), "layout.html", "", nil)
+	if err != nil {
+		t.Fatalf("NewTemplates returned error: %s", err)
+	}
+	var page, empty bytes.Buffer
+	tmpl.Execute(&page, "page.html", nil, false)
+	tmpl.Execute(&empty, "empty.html", nil, false)
+	if page.String() != "<body>page</body>" {
+		t.Errorf("A page definition should replace the layout block default, rendered '%s'", page.String())
+	}
+	if empty.String() != "<body>default</body>" {
+		t.Errorf("A page without a definition should render the layout block default, rendered '%s'", empty.String())
+	}
+}
+
+func TestReloadTemplates(t *testing.T) {
+	dir := templateDir(t)
+	defer os.RemoveAll(dir)
+
+	tmpl, err := NewTemplates(http.Dir(dir), "", "partials", template.FuncMap{"upper": strings.ToUpper})
+	if err != nil {
+		t.Fatalf("NewTemplates returned error: %s", err)
+	}
+
+	e, _ := New(ReloadTemplates(true))
+	e.SetTemplates(tmpl)
+	e.Take("/", "GET", func(c context.Context) {
+		currentCtx(c).Render(200, "plain.html", "gopher")
+	})
+
+	writeTemplates(dir, map[string]string{"plain.html": `<p>{{.}}</p>`}, t)
+	if body := performRender(e, "/"); body != "<p>gopher</p>" {
+		t.Errorf("Rendered new template was '%s'", body)
+	}
+
+	writeTemplates(dir, map[string]string{"plain.html": `<b>{{.}}</b>`}, t)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "plain.html"), later, later)
+	if body := performRender(e, "/"); body != "<b>gopher</b>" {
+		t.Errorf("Rendered changed template was '%s'", body)
+	}
+}
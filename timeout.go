@@ -0,0 +1,241 @@
+package engine
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+type (
+	// timeoutWriter guards a ResponseWriter shared with a Manage chain running
+	// under a deadline. Headers are kept apart until written, and once the
+	// deadline passes any write from the chain is refused, leaving the wrapped
+	// ResponseWriter to the engine. The chain is then answered the status,
+	// size and written state of the ResponseWriter when the deadline passed.
+	timeoutWriter struct {
+		ResponseWriter
+		mu       sync.Mutex
+		header   http.Header
+		timedOut bool
+		status   int
+		size     int
+		written  bool
+	}
+
+	// panicked is a recovered panic and stack from a Manage chain running in
+	// its own goroutine.
+	panicked struct {
+		rcv   interface{}
+		stack []byte
+	}
+)
+
+// SetTimeout sets a timeout for requests to the group and any sub-groups
+// without their own timeout, overriding the engine Timeout. A zero duration
+// sets no timeout.
+func (group *Group) SetTimeout(d time.Duration) {
+	group.timeout = d
+	group.timed = true
+}
+
+func (group *Group) timeoutFor() time.Duration {
+	for g := group; g != nil; g = g.parent {
+		if g.timed {
+			return g.timeout
+		}
+	}
+	return group.engine.Timeout
+}
+
+// timed wraps a Manage registered directly on the engine to run as the chain
+// of a Group.Take route, under the timeout of the base group, or the engine
+// Timeout.
+func (e *Engine) timed(m Manage) Manage {
+	handlers := []Manage{m}
+	return func(c context.Context) {
+		if c == nil {
+			m(c)
+			return
+		}
+		currentCtx(c).serve(c, handlers)
+	}
+}
+
+// serve runs the handlers under the timeout of the Ctx group, or the deadline
+// of the context.Context, if any.
+func (c *Ctx) serve(ctx context.Context, handlers []Manage) {
+	if d := c.group.timeoutFor(); d > 0 {
+		c.runTimeout(ctx, d, handlers)
+	} else if _, ok := ctx.Deadline(); ok {
+		c.runTimeout(ctx, 0, handlers)
+	} else {
+		c.run(ctx, handlers)
+	}
+}
+
+// runTimeout runs the handlers in their own goroutine, with a context.Context
+// carrying any group timeout, or the deadline of the provided context.Context
+// if earlier. When the deadline passes before the handlers return, the engine
+// stops waiting and calls the 503 HttpStatus for the group timeout, or the 504
+// HttpStatus for an earlier deadline, with the timeout attached to the Ctx
+// errors. The engine wins any race over the response: once it stops waiting,
+// every later write from the handlers fails with http.ErrHandlerTimeout, and
+// the status is only written if the handlers had not written one first.
+func (c *Ctx) runTimeout(ctx context.Context, d time.Duration, handlers []Manage) {
+	code := 503
+	var cancel context.CancelFunc
+	if d > 0 {
+		if deadline, ok := ctx.Deadline(); ok && deadline.Before(time.Now().Add(d)) {
+			code = 504
+		}
+		ctx, cancel = context.WithTimeout(ctx, d)
+	} else {
+		code = 504
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	tw := &timeoutWriter{ResponseWriter: c.RW, header: make(http.Header)}
+	for k, v := range c.RW.Header() {
+		tw.header[k] = v
+	}
+	c.RW = tw
+	expired := *c
+	expired.ctx = &requestCtx{Context: c.ctx.Context, curr: &expired}
+	expired.RW = tw.ResponseWriter
+	expired.Errors = append(errorMsgs(nil), c.Errors...)
+	expired.keys = make(map[string]interface{}, len(c.keys))
+	for k, v := range c.keys {
+		expired.keys[k] = v
+	}
+
+	done := make(chan struct{})
+	panics := make(chan panicked, 1)
+	go func() {
+		defer func() {
+			if rcv := recover(); rcv != nil {
+				panics <- panicked{rcv, stack(3)}
+			}
+			close(done)
+		}()
+		c.run(ctx, handlers)
+	}()
+
+	select {
+	case <-done:
+		tw.mu.Lock()
+		tw.copyHeader()
+		tw.mu.Unlock()
+		c.RW = tw.ResponseWriter
+		select {
+		case p := <-panics:
+			c.engine.recovered(c, p.rcv, p.stack)
+		default:
+		}
+	case <-ctx.Done():
+		tw.mu.Lock()
+		tw.timedOut = true
+		tw.status = tw.ResponseWriter.Status()
+		tw.size = tw.ResponseWriter.Size()
+		tw.written = tw.ResponseWriter.Written()
+		written := tw.written
+		tw.mu.Unlock()
+		c.expired = &expired
+		expired.errorTyped(ctx.Err(), ErrorTypeInternal, d.String())
+		if !written {
+			expired.Status(code)
+		}
+		expired.RW.WriteHeaderNow()
+	}
+}
+
+func (w *timeoutWriter) copyHeader() {
+	if !w.ResponseWriter.Written() {
+		dst := w.ResponseWriter.Header()
+		for k, v := range w.header {
+			dst[k] = v
+		}
+	}
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.timedOut {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.timedOut {
+		w.copyHeader()
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Write writes to the wrapped ResponseWriter, or returns
+// http.ErrHandlerTimeout once the deadline has passed.
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.copyHeader()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.timedOut {
+		w.copyHeader()
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	return w.ResponseWriter.Hijack()
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return w.size
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return w.written
+	}
+	return w.ResponseWriter.Written()
+}
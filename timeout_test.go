@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestTimeout(t *testing.T) {
+	e, _ := New(Timeout(20 * time.Millisecond))
+	fast := e.New("/fast")
+	fast.SetTimeout(0)
+
+	var expired *Ctx
+	timedOut := make(chan struct{})
+	e.HttpStatuses[503].Update(func(c context.Context) {
+		expired = currentCtx(c)
+		close(timedOut)
+	})
+
+	// The engine wins the race over the response of a timed out Manage, so
+	// waiting for the 503 orders the late write after it, to be refused.
+	handled := make(chan struct{})
+	e.Take("/slow", "GET", func(c context.Context) {
+		defer close(handled)
+		curr := currentCtx(c)
+		if _, ok := c.Deadline(); !ok {
+			t.Error("Manage should receive a context.Context with a deadline")
+		}
+		<-timedOut
+		curr.RW.Header().Set("X-Late", "late")
+		curr.RW.WriteHeader(200)
+		if _, err := curr.RW.Write([]byte("late")); err != http.ErrHandlerTimeout {
+			t.Errorf("Write after the timeout should fail with ErrHandlerTimeout, was %v", err)
+		}
+	})
+	fast.Take("/slow", "GET", func(c context.Context) {
+		curr := currentCtx(c)
+		if _, ok := c.Deadline(); ok {
+			t.Error("Manage of a group without a timeout should receive no deadline")
+		}
+		time.Sleep(40 * time.Millisecond)
+		curr.RW.Header().Set("X-Late", "late")
+		curr.RW.WriteHeader(200)
+		curr.RW.Write([]byte("late"))
+	})
+
+	w := PerformRequest(e, "GET", "/slow")
+	<-handled
+	if w.Code != 503 {
+		t.Errorf("Status code should be 503, was %d", w.Code)
+	}
+	if w.Body.String() != "" || w.Header().Get("X-Late") != "" {
+		t.Errorf("Timed out Manage should not write the response, wrote '%s' %v", w.Body.String(), w.Header())
+	}
+	if expired == nil || len(expired.Errors) != 1 || expired.Errors[0].Err != context.DeadlineExceeded.Error() {
+		t.Errorf("Timeout should be attached to the Ctx errors")
+	}
+
+	w = PerformRequest(e, "GET", "/fast/slow")
+	if w.Code != 200 || w.Body.String() != "late" || w.Header().Get("X-Late") != "late" {
+		t.Errorf("Group without a timeout should write the response, was %d '%s'", w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutEngineRoutes(t *testing.T) {
+	e, _ := New(Timeout(10 * time.Millisecond))
+	var handled sync.WaitGroup
+	late := func(w http.ResponseWriter, req *http.Request) {
+		defer handled.Done()
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("late"))
+	}
+	e.HandlerFunc("GET", "/func", late)
+	e.Handler("GET", "/handler", http.HandlerFunc(late))
+	e.Manage("GET", "/manage", func(c context.Context) {
+		curr := currentCtx(c)
+		late(curr.RW, curr.request)
+	})
+
+	for _, path := range []string{"/func", "/handler", "/manage"} {
+		handled.Add(1)
+		w := PerformRequest(e, "GET", path)
+		if w.Code != 503 || w.Body.String() == "late" {
+			t.Errorf("Route %s should time out with the engine Timeout, was %d '%s'", path, w.Code, w.Body.String())
+		}
+	}
+	handled.Wait()
+}
+
+func TestTimeoutWriterRace(t *testing.T) {
+	e, _ := New(Timeout(10 * time.Millisecond))
+	timedOut := make(chan struct{})
+	e.HttpStatuses[503].Update(func(c context.Context) { close(timedOut) })
+	handled := make(chan struct{})
+	e.Take("/poll", "GET", func(c context.Context) {
+		defer close(handled)
+		curr := currentCtx(c)
+		// keep reading while the engine writes the timeout status
+		for {
+			select {
+			case <-timedOut:
+				return
+			default:
+				curr.RW.Status()
+				curr.RW.Size()
+				curr.RW.Written()
+			}
+		}
+	})
+
+	w := PerformRequest(e, "GET", "/poll")
+	<-handled
+	if w.Code != 503 {
+		t.Errorf("Status code should be 503, was %d", w.Code)
+	}
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	e, _ := New(Timeout(time.Second), ServePanic(false))
+	e.Take("/panic", "GET", func(c context.Context) { panic("timeout panic") })
+
+	w := PerformRequest(e, "GET", "/panic")
+	if w.Code != 500 {
+		t.Errorf("Status code should be 500, was %d", w.Code)
+	}
+}
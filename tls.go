@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+type (
+	// certReloader serves a certificate loaded from a certificate and key file,
+	// reloading the files when either changes.
+	certReloader struct {
+		engine   *Engine
+		certFile string
+		keyFile  string
+		interval time.Duration
+		mu       sync.Mutex
+		cert     *tls.Certificate
+		modtimes [2]time.Time
+		checked  time.Time
+	}
+)
+
+func newCertReloader(engine *Engine, certFile string, keyFile string) (*certReloader, error) {
+	cr := &certReloader{
+		engine:   engine,
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: engine.CertReload,
+	}
+	modtimes, err := cr.stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := cr.load(modtimes); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+func (cr *certReloader) stat() ([2]time.Time, error) {
+	var modtimes [2]time.Time
+	for i, f := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(f)
+		if err != nil {
+			return modtimes, err
+		}
+		modtimes[i] = info.ModTime()
+	}
+	return modtimes, nil
+}
+
+func (cr *certReloader) load(modtimes [2]time.Time) error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return err
+	}
+	cr.cert, cr.modtimes = &cert, modtimes
+	return nil
+}
+
+// GetCertificate returns the loaded certificate for tls.Config, first
+// reloading the files if the reload interval has passed and either changed.
+// A certificate that fails to reload is kept, and the error published as an
+// EventServer.
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if now := time.Now(); now.Sub(cr.checked) >= cr.interval {
+		cr.checked = now
+		modtimes, err := cr.stat()
+		if err == nil && modtimes != cr.modtimes {
+			err = cr.load(modtimes)
+			if err == nil {
+				cr.engine.publish(EventServer, "server-cert-reload", nil)
+			}
+		}
+		if err != nil {
+			cr.engine.publish(EventServer, fmt.Sprintf("server-cert-error %s", err), nil)
+		}
+	}
+	return cr.cert, nil
+}
+
+// ListenAndServeTLS listens on the Server address and serves the engine over
+// TLS, see ServeTLS.
+func (s *Server) ListenAndServeTLS(certFile string, keyFile string) error {
+	l, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeTLS(l, certFile, keyFile)
+}
+
+// ServeTLS serves the engine over TLS on the listener, as Serve, with the
+// engine TLSConfig and the certificate and key files, which are reloaded when
+// changed. With an engine HTTPRedirect address, plain HTTP requests to the
+// address are redirected to HTTPS on the listener port until ServeTLS
+// returns.
+func (s *Server) ServeTLS(l net.Listener, certFile string, keyFile string) error {
+	cr, err := newCertReloader(s.engine, certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	config := &tls.Config{}
+	if s.engine.TLSConfig != nil {
+		config = s.engine.TLSConfig.Clone()
+	}
+	config.Certificates = nil
+	config.GetCertificate = cr.GetCertificate
+	if len(config.NextProtos) == 0 {
+		config.NextProtos = []string{"http/1.1"}
+	}
+	s.srv.TLSConfig = config
+
+	if s.engine.HTTPRedirect != "" {
+		_, port, _ := net.SplitHostPort(l.Addr().String())
+		rl, err := net.Listen("tcp", s.engine.HTTPRedirect)
+		if err != nil {
+			return err
+		}
+		redirect := s.newRedirect(port)
+		s.mu.Lock()
+		s.redirect = redirect
+		s.mu.Unlock()
+		defer s.closeRedirect()
+		go redirect.Serve(rl)
+		s.engine.publish(EventServer, fmt.Sprintf("server-redirect %s", rl.Addr()), nil)
+	}
+
+	return s.Serve(tls.NewListener(l, config))
+}
+
+// redirectHeaderTimeout bounds reading the request headers of the HTTPS
+// redirect server, which only ever answers with a redirect.
+const redirectHeaderTimeout = 5 * time.Second
+
+// newRedirect returns the HTTPS redirect server to the port, with the engine
+// timeouts and a short header timeout, so idle or slow plain HTTP clients
+// cannot hold connections open.
+func (s *Server) newRedirect(port string) *http.Server {
+	return &http.Server{
+		Handler:           httpsRedirect(port),
+		ReadHeaderTimeout: redirectHeaderTimeout,
+		ReadTimeout:       s.engine.ReadTimeout,
+		WriteTimeout:      s.engine.WriteTimeout,
+		IdleTimeout:       s.engine.IdleTimeout,
+	}
+}
+
+// closeRedirect closes any HTTPS redirect listener of the Server.
+func (s *Server) closeRedirect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.redirect != nil {
+		s.redirect.Close()
+		s.redirect = nil
+	}
+}
+
+// httpsRedirect permanently redirects requests to the same host and URL over
+// HTTPS on the port.
+func httpsRedirect(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, req, "https://"+host+req.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func writeCert(dir string, name string, modtime time.Time, t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kb, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0600)
+	os.Chtimes(certFile, modtime, modtime)
+	os.Chtimes(keyFile, modtime, modtime)
+	return certFile, keyFile
+}
+
+func TestServeTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "engine-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeCert(dir, "first", time.Now(), t)
+
+	e, _ := New(CertReload(0), TLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}))
+	e.Take("/tls", "GET", func(c context.Context) {
+		currentCtx(c).RW.Write([]byte("secure"))
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(l.Addr().String(), e)
+	served := make(chan error, 1)
+	go func() { served <- s.ServeTLS(l, certFile, keyFile) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		DisableKeepAlives: true,
+	}}
+	commonName := func() string {
+		resp, err := client.Get("https://" + l.Addr().String() + "/tls")
+		if err != nil {
+			t.Fatalf("TLS request failed: %s", err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		if string(body) != "secure" {
+			t.Errorf("Body should be secure, was '%s'", body)
+		}
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+
+	if cn := commonName(); cn != "first" {
+		t.Errorf("Certificate should be first, was %s", cn)
+	}
+	writeCert(dir, "second", time.Now().Add(time.Minute), t)
+	if cn := commonName(); cn != "second" {
+		t.Errorf("Changed certificate should be reloaded as second, was %s", cn)
+	}
+
+	s.Shutdown()
+	if err := <-served; err != nil {
+		t.Errorf("ServeTLS should return nil after Shutdown, returned %s", err)
+	}
+}
+
+func TestServeTLSError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "engine-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeCert(dir, "error", time.Now(), t)
+
+	e, _ := New(HTTPRedirect("127.0.0.1:0"))
+	s := e.Events.Subscribe(10, Drop, EventServer)
+	defer s.Unsubscribe()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if err := NewServer(l.Addr().String(), e).ServeTLS(l, certFile, keyFile); err == nil {
+		t.Fatal("ServeTLS on a closed listener should return an error")
+	}
+
+	var redirect string
+	for len(s.C) > 0 {
+		if ev := <-s.C; strings.HasPrefix(ev.Message, "server-redirect ") {
+			redirect = strings.TrimPrefix(ev.Message, "server-redirect ")
+		}
+	}
+	if redirect == "" {
+		t.Fatal("ServeTLS should start the HTTPS redirect")
+	}
+	if conn, err := net.Dial("tcp", redirect); err == nil {
+		conn.Close()
+		t.Errorf("ServeTLS should close the HTTPS redirect listener when serving fails")
+	}
+}
+
+func TestHTTPSRedirectTimeouts(t *testing.T) {
+	e, _ := New(ReadTimeout(2*time.Second), WriteTimeout(3*time.Second), IdleTimeout(4*time.Second))
+	srv := NewServer(":0", e).newRedirect("8443")
+	if srv.ReadHeaderTimeout <= 0 {
+		t.Errorf("HTTPS redirect server should have a ReadHeaderTimeout")
+	}
+	if srv.ReadTimeout != 2*time.Second || srv.WriteTimeout != 3*time.Second || srv.IdleTimeout != 4*time.Second {
+		t.Errorf("HTTPS redirect server should have the engine timeouts, had %s, %s, %s", srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	h := httpsRedirect("8443")
+	req, _ := http.NewRequest("GET", "http://example.com:8080/path?q=1", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusMovedPermanently {
+		t.Errorf("Status code should be %d, was %d", http.StatusMovedPermanently, w.Code)
+	}
+	if l := w.Header().Get("Location"); l != "https://example.com:8443/path?q=1" {
+		t.Errorf("Location should be https://example.com:8443/path?q=1, was %s", l)
+	}
+
+	w = httptest.NewRecorder()
+	httpsRedirect("443").ServeHTTP(w, req)
+	if l := w.Header().Get("Location"); !strings.HasPrefix(l, "https://example.com/") {
+		t.Errorf("Location should omit the default https port, was %s", l)
+	}
+}
@@ -0,0 +1,315 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+type (
+	// FieldError is a validation failure for a single field, naming the field,
+	// the failed rule and a message.
+	FieldError struct {
+		Field   string `json:"field"`
+		Rule    string `json:"rule"`
+		Message string `json:"message"`
+	}
+
+	// ValidationErrors are the FieldError for every failed field rule.
+	ValidationErrors []FieldError
+
+	// structRules are the parsed validate rules of the exported fields of a
+	// struct type, or the error of a malformed tag.
+	structRules struct {
+		fields []fieldRules
+		err    error
+	}
+
+	fieldRules struct {
+		index int
+		name  string
+		rules []rule
+	}
+
+	// rule is a parsed validate rule, with the number, options or regular
+	// expression of its argument.
+	rule struct {
+		key     string
+		arg     string
+		n       float64
+		options []string
+		re      *regexp.Regexp
+	}
+)
+
+var (
+	emailRegexp = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+	typeRules   = make(map[reflect.Type]*structRules)
+	typeRulesMu sync.Mutex
+)
+
+func (f FieldError) Error() string {
+	return fmt.Sprintf("%s %s", f.Field, f.Message)
+}
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, f := range v {
+		msgs[i] = f.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate checks the fields of the struct, or struct pointer, v against the
+// comma separated rules of their `validate` tag, returning ValidationErrors for
+// any failure. The rules are:
+//
+//	required    the field is not a zero value
+//	min=N       numbers are at least N, strings and slices have length at least N
+//	max=N       numbers are at most N, strings and slices have length at most N
+//	len=N       strings and slices have length N
+//	oneof=a b   the field is one of the space separated values
+//	email       the field is an email address
+//	regex=expr  the field matches the regular expression; must be the last rule
+//
+// Fields that are not required and hold a zero value are not checked, and
+// nested structs are validated with their fields named parent.child. The rules
+// of a struct type are parsed once, on first use, and a malformed tag returns
+// an error describing it instead of ValidationErrors.
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return newError("cannot validate %T, only a struct or struct pointer", v)
+	}
+	errs, err := validateStruct("", rv)
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(prefix string, rv reflect.Value) (ValidationErrors, error) {
+	sr := rulesFor(rv.Type())
+	if sr.err != nil {
+		return nil, sr.err
+	}
+	var errs ValidationErrors
+	for _, f := range sr.fields {
+		name := prefix + f.name
+		fv := rv.Field(f.index)
+		if len(f.rules) > 0 {
+			errs = append(errs, validateField(name, fv, f.rules)...)
+		}
+		if fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct {
+			nested, err := validateStruct(name+".", fv)
+			if err != nil {
+				return nil, err
+			}
+			errs = append(errs, nested...)
+		}
+	}
+	return errs, nil
+}
+
+// rulesFor returns the parsed rules of the struct type, parsing them once.
+func rulesFor(rt reflect.Type) *structRules {
+	typeRulesMu.Lock()
+	defer typeRulesMu.Unlock()
+	sr, ok := typeRules[rt]
+	if !ok {
+		sr = parseStruct(rt)
+		typeRules[rt] = sr
+	}
+	return sr
+}
+
+func parseStruct(rt reflect.Type) *structRules {
+	sr := &structRules{}
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		fr := fieldRules{index: i, name: fieldName(f)}
+		if tag := f.Tag.Get("validate"); tag != "" && tag != "-" {
+			rules, err := parseRules(tag)
+			if err != nil {
+				sr.err = newError("invalid validate tag of %s field %s: %s", rt, f.Name, err)
+				return sr
+			}
+			fr.rules = rules
+		}
+		sr.fields = append(sr.fields, fr)
+	}
+	return sr
+}
+
+func parseRules(tag string) ([]rule, error) {
+	var rules []rule
+	for _, r := range splitRules(tag) {
+		ru := rule{key: r}
+		if i := strings.IndexByte(r, '='); i >= 0 {
+			ru.key, ru.arg = r[:i], r[i+1:]
+		}
+		switch ru.key {
+		case "required", "email":
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(ru.arg, 64)
+			if err != nil {
+				return nil, newError("rule %s needs a number", r)
+			}
+			ru.n = n
+		case "oneof":
+			ru.options = strings.Fields(ru.arg)
+		case "regex":
+			re, err := regexp.Compile(ru.arg)
+			if err != nil {
+				return nil, newError("rule %s: %s", r, err)
+			}
+			ru.re = re
+		default:
+			return nil, newError("unknown rule %s", r)
+		}
+		rules = append(rules, ru)
+	}
+	return rules, nil
+}
+
+// fieldName names a field by the first of its json, form, xml or param tag
+// names, or the field name without a tag name.
+func fieldName(f reflect.StructField) string {
+	for _, key := range []string{"json", "form", "xml", "param"} {
+		name := strings.Split(f.Tag.Get(key), ",")[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+func validateField(name string, fv reflect.Value, rules []rule) ValidationErrors {
+	var errs ValidationErrors
+	fail := func(rule, format string, a ...interface{}) {
+		errs = append(errs, FieldError{name, rule, fmt.Sprintf(format, a...)})
+	}
+
+	if isZero(fv) {
+		for _, r := range rules {
+			if r.key == "required" {
+				fail(r.key, "is required")
+			}
+		}
+		return errs
+	}
+
+	value := fmt.Sprint(reflect.Indirect(fv).Interface())
+
+	for _, r := range rules {
+		switch r.key {
+		case "min", "max", "len":
+			size, length := sizeOf(fv)
+			switch {
+			case r.key == "min" && size < r.n:
+				if length {
+					fail(r.key, "must have a length of at least %s", r.arg)
+				} else {
+					fail(r.key, "must be at least %s", r.arg)
+				}
+			case r.key == "max" && size > r.n:
+				if length {
+					fail(r.key, "must have a length of at most %s", r.arg)
+				} else {
+					fail(r.key, "must be at most %s", r.arg)
+				}
+			case r.key == "len" && size != r.n:
+				fail(r.key, "must have a length of %s", r.arg)
+			}
+		case "oneof":
+			found := false
+			for _, o := range r.options {
+				if o == value {
+					found = true
+					break
+				}
+			}
+			if !found {
+				fail(r.key, "must be one of %s", strings.Join(r.options, ", "))
+			}
+		case "email":
+			if !emailRegexp.MatchString(value) {
+				fail(r.key, "must be an email address")
+			}
+		case "regex":
+			if !r.re.MatchString(value) {
+				fail(r.key, "must match %s", r.arg)
+			}
+		}
+	}
+	return errs
+}
+
+// splitRules splits a validate tag on commas, except within a final regex rule.
+func splitRules(tag string) []string {
+	if i := strings.Index(tag, "regex="); i >= 0 {
+		rules := splitRules(strings.TrimSuffix(tag[:i], ","))
+		return append(rules, tag[i:])
+	}
+	if tag == "" {
+		return nil
+	}
+	return strings.Split(tag, ",")
+}
+
+// sizeOf returns the number for numeric values, or the length of strings,
+// slices, maps and arrays, reporting whether the size is a length.
+func sizeOf(fv reflect.Value) (float64, bool) {
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), false
+	case reflect.String:
+		return float64(utf8.RuneCountInString(fv.String())), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(fv.Len()), true
+	case reflect.Ptr:
+		if !fv.IsNil() {
+			return sizeOf(fv.Elem())
+		}
+	}
+	return 0, false
+}
+
+func isZero(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return fv.Len() == 0
+	case reflect.Bool:
+		return !fv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return fv.Float() == 0
+	case reflect.Ptr, reflect.Interface:
+		return fv.IsNil()
+	}
+	return reflect.DeepEqual(fv.Interface(), reflect.Zero(fv.Type()).Interface())
+}
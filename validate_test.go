@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+type validated struct {
+	Name    string   `json:"name" validate:"required,min=2,max=8"`
+	Age     int      `json:"age" validate:"min=18,max=130"`
+	Code    string   `json:"code" validate:"len=3,regex=^[A-Z]{1,3}$"`
+	Color   string   `json:"color" validate:"oneof=red green blue"`
+	Email   string   `json:"email" validate:"email"`
+	Tags    []string `json:"tags" validate:"max=2"`
+	Address struct {
+		City string `json:"city" validate:"required"`
+	} `json:"address"`
+}
+
+func validationRules(err error) []string {
+	var rules []string
+	if ve, ok := err.(ValidationErrors); ok {
+		for _, fe := range ve {
+			rules = append(rules, fe.Field+":"+fe.Rule)
+		}
+	}
+	return rules
+}
+
+func TestValidate(t *testing.T) {
+	v := validated{Name: "gopher", Age: 30, Code: "ABC", Color: "red", Email: "gopher@golang.org"}
+	v.Address.City = "Gopherton"
+	if err := Validate(&v); err != nil {
+		t.Errorf("Validate of a valid struct returned error: %s", err)
+	}
+
+	v = validated{Name: "g", Age: 12, Code: "AB1", Color: "pink", Email: "gopher", Tags: []string{"a", "b", "c"}}
+	expected := []string{"name:min", "age:min", "code:regex", "color:oneof", "email:email", "tags:max", "address.city:required"}
+	if rules := validationRules(Validate(v)); !reflect.DeepEqual(rules, expected) {
+		t.Errorf("Validate failed rules should be %v, were %v", expected, rules)
+	}
+
+	v = validated{Age: 200, Code: "ABCD"}
+	v.Address.City = "Gopherton"
+	expected = []string{"name:required", "age:max", "code:len", "code:regex"}
+	if rules := validationRules(Validate(v)); !reflect.DeepEqual(rules, expected) {
+		t.Errorf("Validate failed rules should be %v, were %v", expected, rules)
+	}
+
+	if err := Validate("string"); err == nil {
+		t.Error("Validate of a non struct should return an error")
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	for _, v := range []interface{}{
+		struct {
+			Age int `validate:"min=abc"`
+		}{1},
+		struct {
+			Name string `validate:"unknown"`
+		}{"gopher"},
+		struct {
+			Code string `validate:"regex=[A-Z"`
+		}{"ABC"},
+		struct {
+			Nested struct {
+				Name string `validate:"required,size=2"`
+			}
+		}{},
+	} {
+		for i := 0; i < 2; i++ {
+			err := Validate(v)
+			if _, ok := err.(ValidationErrors); ok || err == nil || !strings.Contains(err.Error(), "invalid validate tag") {
+				t.Errorf("Validate of a malformed tag should return an error describing it, returned %v", err)
+			}
+		}
+	}
+
+	e, _ := New()
+	e.Take("/malformed", "POST", func(c context.Context) {
+		var v struct {
+			Age int `json:"age" validate:"max=old"`
+		}
+		currentCtx(c).Bind(&v)
+	})
+	req, _ := http.NewRequest("POST", "/malformed", strings.NewReader(`{"age":12}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Bind with a malformed validate tag should call the 500 HttpStatus, was %d", w.Code)
+	}
+}
+
+func performValidation(e *Engine, body string) *httptest.ResponseRecorder {
+	passed := false
+	e.Take("/validate", "POST", func(c context.Context) {
+		var v validated
+		if err := currentCtx(c).Bind(&v); err == nil {
+			passed = true
+		}
+	})
+	req, _ := http.NewRequest("POST", "/validate", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	if passed {
+		panic("invalid request passed validation")
+	}
+	return w
+}
+
+func TestBindValidation(t *testing.T) {
+	e, _ := New()
+	w := performValidation(e, `{"name":"gopher","age":12}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Status code should be %v, was %d", http.StatusBadRequest, w.Code)
+	}
+	var body struct {
+		Status int
+		Errors []FieldError
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Body should be json, was '%s': %s", w.Body.String(), err)
+	}
+	expected := []FieldError{
+		{"age", "min", "must be at least 18"},
+		{"address.city", "required", "is required"},
+	}
+	if body.Status != 400 || !reflect.DeepEqual(body.Errors, expected) {
+		t.Errorf("Body errors should be %v, were %v", expected, body.Errors)
+	}
+
+	e, _ = New(HTMLStatus(true))
+	w = performValidation(e, `{"name":"gopher","age":12}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Status code should be %v, was %d", http.StatusBadRequest, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "<li>age must be at least 18</li>") {
+		t.Errorf("Body should list field errors as html, was '%s'", w.Body.String())
+	}
+}